@@ -8,12 +8,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+
 	_ "github.com/WangZhaoye/go-task-processor/docs"
 	"github.com/WangZhaoye/go-task-processor/internal/cache"
 	"github.com/WangZhaoye/go-task-processor/internal/config"
 	"github.com/WangZhaoye/go-task-processor/internal/db"
 	"github.com/WangZhaoye/go-task-processor/internal/handler"
 	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/WangZhaoye/go-task-processor/internal/scheduler"
+	"github.com/WangZhaoye/go-task-processor/internal/service"
+	"github.com/WangZhaoye/go-task-processor/internal/worker"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -22,13 +33,57 @@ import (
 func main() {
 	config.LoadConfig()
 	db.InitDB()
-	mq.InitRabbitMQ()
 	cache.InitRedis()
+	mq.InitBroker()
+
+	// 任务类型及其设置以 Worker 启动时注册到数据库的为准；内置类型在 Worker 注册之前使用编译时的设置，
+	// 其他没有 Worker 注册过的类型在提交时被拒绝
+	service.SetTaskTypeChecker(service.NewTaskTypeCatalog(worker.BuiltinTaskTypes()...))
+
 	r := gin.Default()
 	handler.RegisterRoutes(r)
 
 	// ✅ Swagger 文档路由
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	r.Run(":" + config.Cfg.Port)
+	srv := &http.Server{
+		Addr:    ":" + config.Cfg.Port,
+		Handler: r,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start API server: %v", err)
+		}
+	}()
+	log.Printf("🚀 API server listening on %s\n", srv.Addr)
+
+	// API 实例也可以运行调度器，多个实例之间通过数据库抢占保证只触发一次
+	var wg sync.WaitGroup
+	if config.Cfg.SchedulerEnabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scheduler.Run(ctx, config.Cfg.DispatchInterval)
+		}()
+	}
+
+	<-ctx.Done()
+	log.Println("🛑 Shutting down API server...")
+
+	// 停止接收新请求，等待进行中的请求完成
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("❌ API server forced to shutdown: %v", err)
+	}
+	wg.Wait()
+
+	mq.Close()
+	cache.Close()
+	db.Close()
+	log.Println("👋 API server exited")
 }
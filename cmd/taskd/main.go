@@ -0,0 +1,120 @@
+// taskd 单进程开发模式：在一个进程内运行 API、Worker 和调度器
+//
+// 数据库使用 SQLite，消息队列使用进程内 Broker，缓存使用进程内 Redis，不依赖任何外部服务：
+//
+//	go run ./cmd/taskd
+//	go run ./cmd/taskd -db taskd.db -port 8080
+//
+// 进程内队列中的消息随进程退出丢失，使用 -db 保存的数据重启时会重新发布所有 pending 任务，
+// 执行中的任务在租约过期后由调度器回收。只适合本地开发和 CI，不要用于生产环境。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	_ "github.com/WangZhaoye/go-task-processor/docs"
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/config"
+	"github.com/WangZhaoye/go-task-processor/internal/db"
+	"github.com/WangZhaoye/go-task-processor/internal/handler"
+	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/WangZhaoye/go-task-processor/internal/scheduler"
+	"github.com/WangZhaoye/go-task-processor/internal/service"
+	"github.com/WangZhaoye/go-task-processor/internal/worker"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+func main() {
+	dbPath := flag.String("db", ":memory:", "SQLite database file, :memory: keeps everything in memory")
+	port := flag.String("port", "", "HTTP port (defaults to PORT)")
+	queues := flag.String("queues", "", "queues to consume with weights, e.g. critical:6,default:3 (defaults to WORKER_QUEUES)")
+	flag.Parse()
+
+	config.LoadConfig()
+	config.Cfg.DBDriver = "sqlite"
+	config.Cfg.DBUrl = *dbPath
+	config.Cfg.Broker = "memory"
+	if *port != "" {
+		config.Cfg.Port = *port
+	}
+	if *queues != "" {
+		weights, err := config.ParseQueueWeights(*queues, config.Cfg.TaskQueues)
+		if err != nil {
+			log.Fatalf("Invalid -queues: %v", err)
+		}
+		config.Cfg.WorkerQueues = weights
+	}
+	db.InitDB()
+	cache.InitEmbeddedRedis()
+	mq.InitBroker()
+
+	// 上次退出时已发布但还没被消费的消息随进程内队列一起丢失，重新写入 outbox 后由调度器发布
+	if n, err := service.RepublishPendingTasks(); err != nil {
+		log.Fatalf("Failed to start taskd: %v", err)
+	} else if n > 0 {
+		log.Printf("♻️ Republishing %d pending tasks from %s\n", n, *dbPath)
+	}
+
+	registry := worker.NewRegistry()
+	registry.Use(worker.Recover(), worker.Logging())
+	worker.RegisterBuiltins(registry)
+	// API 和调度器使用 Worker 注册到数据库的任务类型设置
+	service.SetTaskTypeChecker(service.NewTaskTypeCatalog(worker.BuiltinTaskTypes()...))
+
+	r := gin.Default()
+	handler.RegisterRoutes(r)
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	srv := &http.Server{
+		Addr:    ":" + config.Cfg.Port,
+		Handler: r,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start API server: %v", err)
+		}
+	}()
+	log.Printf("🚀 taskd listening on %s\n", srv.Addr)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		scheduler.Run(ctx, config.Cfg.DispatchInterval)
+	}()
+	go func() {
+		defer wg.Done()
+		if err := worker.New(registry).Run(ctx); err != nil {
+			log.Printf("❌ Worker stopped: %v", err)
+		}
+		stop()
+	}()
+
+	<-ctx.Done()
+	log.Println("🛑 Shutting down taskd...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("❌ API server forced to shutdown: %v", err)
+	}
+	wg.Wait()
+
+	mq.Close()
+	cache.Close()
+	db.Close()
+	log.Println("👋 taskd exited")
+}
@@ -15,9 +15,721 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/dead-tasks": {
+            "get": {
+                "description": "List tasks that exhausted their retries or could not be processed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dead-letter"
+                ],
+                "summary": "List dead tasks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by task type",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/dead-tasks/requeue": {
+            "post": {
+                "description": "Requeue every dead task, optionally only those of one type",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dead-letter"
+                ],
+                "summary": "Requeue all dead tasks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only requeue tasks of this type",
+                        "name": "type",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/dead-tasks/{id}": {
+            "get": {
+                "description": "Get a dead task including its last error",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dead-letter"
+                ],
+                "summary": "Get a dead task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Task"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/dead-tasks/{id}/requeue": {
+            "post": {
+                "description": "Reset a dead task to pending with a fresh retry budget and publish it again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dead-letter"
+                ],
+                "summary": "Requeue a dead task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Task"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schedules": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "List recurring tasks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Schedule"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Define a task that is created on a cron schedule",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Create a recurring task",
+                "parameters": [
+                    {
+                        "description": "Schedule",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.ScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schedules/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Get a recurring task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Schedule"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace a schedule definition; the next run time is recalculated",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Update a recurring task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Schedule",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.ScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a schedule; tasks already created by it are not affected",
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Delete a recurring task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/tasks": {
+            "get": {
+                "description": "List tasks matching the filters. Pages are addressed by next_cursor (keyset pagination, stable while tasks are being added);\noffset is still accepted for small result sets. With format=ndjson all matching tasks are streamed one JSON object per line, ignoring limit.\nFields inside payload and result are matched with payload.\u003cpath\u003e=value or result.\u003cpath\u003e=value, e.g. payload.user.id=42;\npath segments are separated by dots, numeric segments index arrays, and values are compared as text.",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "List tasks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated statuses, e.g. pending,running; unknown statuses are rejected with 400",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Task type",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Queue name",
+                        "name": "queue",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Exact priority",
+                        "name": "priority",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum priority",
+                        "name": "min_priority",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Exact retry count",
+                        "name": "retry_count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum retry count",
+                        "name": "min_retry_count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated tags, tasks must have all of them",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after (RFC3339)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (RFC3339)",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Updated at or after (RFC3339)",
+                        "name": "updated_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Updated before (RFC3339)",
+                        "name": "updated_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "priority (default), created_at or updated_at; ties are broken by created_at and id",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "desc (default) or asc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset, cannot be combined with cursor",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (default) or ndjson",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Submit a task to be processed asynchronously, or at run_at / after delay.\nRepeating a request with the same Idempotency-Key returns the original task with 200.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Create a new task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Task",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.TaskRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Task"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.Task"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/batch": {
+            "post": {
+                "description": "Submit up to 5000 tasks in one request. Plain tasks are inserted in one transaction and published together;\ntasks with idempotency_key or unique deduplication are submitted one by one. Every task gets its own result with the\nstatus code it would have got from POST /tasks. With atomic=true nothing is created unless every task is valid.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Create tasks in bulk",
+                "parameters": [
+                    {
+                        "description": "Tasks",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.BatchTaskRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Results of a non-atomic batch, check each item",
+                        "schema": {
+                            "$ref": "#/definitions/service.BatchTaskResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "All tasks of an atomic batch were created",
+                        "schema": {
+                            "$ref": "#/definitions/service.BatchTaskResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "An atomic batch was rejected",
+                        "schema": {
+                            "$ref": "#/definitions/service.BatchTaskResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An atomic batch could not be saved",
+                        "schema": {
+                            "$ref": "#/definitions/service.BatchTaskResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/cancel": {
+            "post": {
+                "description": "Cancel a task that has not finished. Pending tasks are skipped by the worker;\nrunning tasks are signalled through their handler context.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Cancel a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Task"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/history": {
+            "get": {
+                "description": "List the status changes of a task in order, including who made them (worker ID, api, scheduler or reaper)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Get task history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.TaskEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/reschedule": {
             "post": {
-                "description": "Submit a task to be processed asynchronously",
+                "description": "Change the run time of a scheduled task that has not started yet",
                 "consumes": [
                     "application/json"
                 ],
@@ -27,21 +739,34 @@ const docTemplate = `{
                 "tags": [
                     "tasks"
                 ],
-                "summary": "Create a new task",
+                "summary": "Reschedule a task",
                 "parameters": [
                     {
-                        "description": "Task",
-                        "name": "task",
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New run time",
+                        "name": "schedule",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Task"
+                            "$ref": "#/definitions/service.RescheduleRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Task"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -49,8 +774,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -63,6 +797,132 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "model.ErrorKind": {
+            "type": "string",
+            "enum": [
+                "retryable",
+                "permanent",
+                "rate_limited",
+                "timed_out",
+                "lease_lost"
+            ],
+            "x-enum-comments": {
+                "ErrorLeaseLost": "Worker 停止心跳，租约过期后被回收，按重试策略重试",
+                "ErrorPermanent": "永久错误，不再重试",
+                "ErrorRateLimited": "被限流，等待后重试且不计入重试次数",
+                "ErrorRetryable": "普通错误，按重试策略重试",
+                "ErrorTimedOut": "执行超时，按重试策略重试"
+            },
+            "x-enum-descriptions": [
+                "普通错误，按重试策略重试",
+                "永久错误，不再重试",
+                "被限流，等待后重试且不计入重试次数",
+                "执行超时，按重试策略重试",
+                "Worker 停止心跳，租约过期后被回收，按重试策略重试"
+            ],
+            "x-enum-varnames": [
+                "ErrorRetryable",
+                "ErrorPermanent",
+                "ErrorRateLimited",
+                "ErrorTimedOut",
+                "ErrorLeaseLost"
+            ]
+        },
+        "model.OverlapPolicy": {
+            "type": "string",
+            "enum": [
+                "allow",
+                "skip"
+            ],
+            "x-enum-comments": {
+                "OverlapAllow": "照常创建新任务",
+                "OverlapSkip": "跳过本次触发"
+            },
+            "x-enum-descriptions": [
+                "照常创建新任务",
+                "跳过本次触发"
+            ],
+            "x-enum-varnames": [
+                "OverlapAllow",
+                "OverlapSkip"
+            ]
+        },
+        "model.RetryPolicy": {
+            "type": "object",
+            "properties": {
+                "base_delay": {
+                    "description": "第一次重试前的等待时间",
+                    "type": "string"
+                },
+                "jitter": {
+                    "description": "随机抖动比例，0.2 表示 ±20%",
+                    "type": "number"
+                },
+                "max_attempts": {
+                    "description": "最多执行次数（包含第一次）",
+                    "type": "integer"
+                },
+                "max_delay": {
+                    "description": "等待时间上限",
+                    "type": "string"
+                },
+                "multiplier": {
+                    "description": "每次重试等待时间的倍数",
+                    "type": "number"
+                }
+            }
+        },
+        "model.Schedule": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "cron": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_run_at": {
+                    "type": "string"
+                },
+                "last_task_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "next_run_at": {
+                    "type": "string"
+                },
+                "overlap_policy": {
+                    "$ref": "#/definitions/model.OverlapPolicy"
+                },
+                "payload_template": {
+                    "description": "text/template 模板，可使用 {{.ScheduledTime}} 等字段",
+                    "type": "string"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "retry_policy": {
+                    "$ref": "#/definitions/model.RetryPolicy"
+                },
+                "timezone": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
         "model.Task": {
             "type": "object",
             "properties": {
@@ -72,37 +932,339 @@ const docTemplate = `{
                 "createdAt": {
                     "type": "string"
                 },
+                "error_kind": {
+                    "$ref": "#/definitions/model.ErrorKind"
+                },
+                "expires_at": {
+                    "description": "超过该时间仍未开始执行则丢弃",
+                    "type": "string"
+                },
+                "heartbeat_at": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
+                "idempotency_key": {
+                    "description": "客户端提供的幂等键，窗口期内重复提交返回同一个任务",
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "lease_expires_at": {
+                    "type": "string"
+                },
+                "next_run_at": {
+                    "type": "string"
+                },
                 "payload": {
+                    "description": "任意 JSON，旧客户端提交的字符串保存为 JSON 字符串",
+                    "type": "object"
+                },
+                "priority": {
+                    "description": "0-9，数值越大越先执行",
+                    "type": "integer"
+                },
+                "queue": {
+                    "description": "任务所在的命名队列",
                     "type": "string"
                 },
                 "result": {
-                    "type": "string"
+                    "description": "Handler 通过 worker.SetResult 记录的结构化结果",
+                    "type": "object"
+                },
+                "retry_count": {
+                    "type": "integer"
+                },
+                "retry_policy": {
+                    "$ref": "#/definitions/model.RetryPolicy"
                 },
                 "status": {
                     "$ref": "#/definitions/model.TaskStatus"
                 },
+                "summary": {
+                    "description": "人可读的执行结果，例如失败原因",
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "按标签过滤时使用 task_tags 表",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "timeout": {
+                    "description": "单次执行超时，0 表示使用任务类型的默认值",
+                    "type": "string"
+                },
+                "unique_key": {
+                    "description": "唯一任务的去重键，同类型中相同的键同一时间只有一个任务",
+                    "type": "string"
+                },
                 "updatedAt": {
                     "type": "string"
+                },
+                "version": {
+                    "description": "每次更新加 1，用于乐观并发控制",
+                    "type": "integer"
+                },
+                "worker_id": {
+                    "description": "running 任务的租约，Worker 执行期间定期续期，过期后由调度器回收",
+                    "type": "string"
+                }
+            }
+        },
+        "model.TaskEvent": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "触发变更的一方：Worker ID、api、scheduler 或 reaper",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "from": {
+                    "description": "变更前的状态，创建任务时为空",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.TaskStatus"
+                        }
+                    ]
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "message": {
+                    "description": "变更原因，例如失败时的错误信息",
+                    "type": "string"
+                },
+                "task_id": {
+                    "type": "string"
+                },
+                "to": {
+                    "$ref": "#/definitions/model.TaskStatus"
                 }
             }
         },
         "model.TaskStatus": {
             "type": "string",
             "enum": [
+                "scheduled",
                 "pending",
                 "running",
+                "retrying",
                 "success",
-                "failed"
+                "failed",
+                "dead",
+                "cancelled",
+                "expired"
+            ],
+            "x-enum-comments": {
+                "StatusDead": "重试耗尽或无法处理，已进入死信队列",
+                "StatusExpired": "超过 expires_at 仍未开始执行，已丢弃",
+                "StatusRetrying": "等待 next_run_at 到期后重新入队",
+                "StatusScheduled": "定时任务，等待 next_run_at 到期后入队"
+            },
+            "x-enum-descriptions": [
+                "定时任务，等待 next_run_at 到期后入队",
+                "",
+                "",
+                "等待 next_run_at 到期后重新入队",
+                "",
+                "",
+                "重试耗尽或无法处理，已进入死信队列",
+                "",
+                "超过 expires_at 仍未开始执行，已丢弃"
             ],
             "x-enum-varnames": [
+                "StatusScheduled",
                 "StatusPending",
                 "StatusRunning",
+                "StatusRetrying",
                 "StatusSuccess",
-                "StatusFalied"
+                "StatusFalied",
+                "StatusDead",
+                "StatusCancelled",
+                "StatusExpired"
             ]
+        },
+        "service.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "task": {
+                    "$ref": "#/definitions/model.Task"
+                }
+            }
+        },
+        "service.BatchTaskRequest": {
+            "type": "object",
+            "required": [
+                "tasks"
+            ],
+            "properties": {
+                "atomic": {
+                    "description": "可选，为 true 时任何一个任务校验失败都不创建任何任务；原子模式不支持幂等键和唯一任务",
+                    "type": "boolean"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.TaskRequest"
+                    }
+                }
+            }
+        },
+        "service.BatchTaskResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "新创建的任务数",
+                    "type": "integer"
+                },
+                "failed": {
+                    "description": "失败的任务数，原子模式下包括因其他任务失败而没有创建的任务",
+                    "type": "integer"
+                },
+                "results": {
+                    "description": "与请求中的 tasks 一一对应",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.BatchItemResult"
+                    }
+                }
+            }
+        },
+        "service.RescheduleRequest": {
+            "type": "object",
+            "properties": {
+                "delay": {
+                    "type": "string"
+                },
+                "run_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.ScheduleRequest": {
+            "type": "object",
+            "required": [
+                "cron",
+                "name",
+                "payload_template",
+                "type"
+            ],
+            "properties": {
+                "cron": {
+                    "description": "例如 \"0 2 * * *\" 或 \"@daily\"",
+                    "type": "string"
+                },
+                "enabled": {
+                    "description": "默认 true",
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "overlap_policy": {
+                    "description": "allow 或 skip，默认 skip",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.OverlapPolicy"
+                        }
+                    ]
+                },
+                "payload_template": {
+                    "description": "渲染结果是 JSON 时作为 JSON payload，否则作为 JSON 字符串",
+                    "type": "string"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "retry_policy": {
+                    "$ref": "#/definitions/model.RetryPolicy"
+                },
+                "timezone": {
+                    "description": "IANA 时区，默认 UTC",
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.TaskRequest": {
+            "type": "object",
+            "required": [
+                "payload",
+                "type"
+            ],
+            "properties": {
+                "delay": {
+                    "description": "可选，延迟指定时间后执行，例如 \"10m\"",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "可选，超过该时间仍未开始执行则丢弃",
+                    "type": "string"
+                },
+                "idempotency_key": {
+                    "description": "可选，幂等键，也可以通过 Idempotency-Key 请求头指定；IDEMPOTENCY_WINDOW 内重复提交返回原任务",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "任意 JSON；字符串按原样保存为 JSON 字符串",
+                    "type": "object"
+                },
+                "priority": {
+                    "description": "可选，0-9，数值越大越先执行",
+                    "type": "integer"
+                },
+                "retry_policy": {
+                    "description": "可选，覆盖任务类型的重试策略",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.RetryPolicy"
+                        }
+                    ]
+                },
+                "run_at": {
+                    "description": "可选，在指定时间执行",
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "可选，任务标签，用于查询时过滤",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "timeout": {
+                    "description": "可选，单次执行超时，覆盖任务类型的默认值",
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "unique_key": {
+                    "description": "可选，唯一任务的去重键，默认由 payload 计算；去重时间窗口内相同键的任务未结束时返回该任务",
+                    "type": "string"
+                },
+                "unique_ttl": {
+                    "description": "可选，去重时间窗口，覆盖任务类型的设置",
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	UniqueKeyPrefix  = "unique:"      // 唯一任务入队时占用的 key 前缀，值为占用该 key 的任务 ID
+	UniqueLockPrefix = "unique_lock:" // 唯一任务执行锁的 key 前缀，值为持有锁的 Worker 的令牌
+)
+
+// 锁的值是持有者的令牌，只有令牌匹配时才能续期、替换或释放，避免误删其他持有者的锁
+var (
+	renewLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+	replaceLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	return 1
+end
+return 0
+`)
+	releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+// AcquireLock 尝试以 token 获取 key 上的锁，ttl 后自动释放；已被其他持有者占用时返回 false
+func AcquireLock(key, token string, ttl time.Duration) (bool, error) {
+	return RDB.SetNX(ctx, key, token, ttl).Result()
+}
+
+// LockHolder 返回当前持有锁的令牌，锁不存在时 ok 为 false
+func LockHolder(key string) (token string, ok bool, err error) {
+	token, err = RDB.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return token, true, nil
+}
+
+// RenewLock 延长 token 持有的锁，锁已过期或被其他持有者占用时返回 false
+func RenewLock(key, token string, ttl time.Duration) (bool, error) {
+	n, err := renewLockScript.Run(ctx, RDB, []string{key}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// ReplaceLock 仅当锁仍由 oldToken 持有时把它转交给 newToken
+func ReplaceLock(key, oldToken, newToken string, ttl time.Duration) (bool, error) {
+	n, err := replaceLockScript.Run(ctx, RDB, []string{key}, oldToken, newToken, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// ReleaseLock 释放 token 持有的锁，锁已不属于 token 时不做任何事
+func ReleaseLock(key, token string) error {
+	return releaseLockScript.Run(ctx, RDB, []string{key}, token).Err()
+}
@@ -6,23 +6,60 @@ import (
 	"log"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 )
 
 var (
 	RDB *redis.Client
 	ctx = context.Background()
+
+	embedded     *miniredis.Miniredis // InitEmbeddedRedis 启动的进程内 Redis
+	embeddedStop chan struct{}
 )
 
 const (
 	TaskCacheExpiration = 30 * time.Minute // 任务缓存过期时间
 	TaskKeyPrefix       = "task:"          // 任务缓存key前缀
 	TaskStatusPrefix    = "task_status:"   // 任务状态缓存key前缀
+	TaskCancelChannel   = "task_cancel"    // 任务取消信号的 pub/sub 频道
 )
 
 func InitRedis() {
+	connect("localhost:6379")
+	log.Println("✅ Redis connected")
+}
+
+// InitEmbeddedRedis 在进程内启动一个 Redis（miniredis）并连接，用于单进程开发模式，数据随进程退出丢失
+func InitEmbeddedRedis() {
+	srv, err := miniredis.Run()
+	if err != nil {
+		log.Fatalf("❌ Failed to start embedded Redis: %v", err)
+	}
+	embedded = srv
+	connect(srv.Addr())
+
+	// miniredis 的过期时间不随真实时间流逝，需要定期推进
+	embeddedStop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				srv.FastForward(time.Second)
+			case <-embeddedStop:
+				return
+			}
+		}
+	}()
+
+	log.Printf("✅ Embedded Redis started on %s\n", srv.Addr())
+}
+
+func connect(addr string) {
 	RDB = redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
+		Addr:     addr,
 		Password: "",
 		DB:       0,
 	})
@@ -30,17 +67,23 @@ func InitRedis() {
 	if err != nil {
 		log.Fatalf("❌ Failed to connect to Redis: %v", err)
 	}
-
-	log.Println("✅ Redis connected")
 }
 
-func SetIfNotExist(key string) bool {
-	ok, err := RDB.SetNX(ctx, key, "1", time.Minute).Result()
-	if err != nil {
-		log.Printf("❌ Redis SetNX failed: %v", err)
-		return false
+// Close 关闭 Redis 连接
+func Close() {
+	if RDB == nil {
+		return
+	}
+	if err := RDB.Close(); err != nil {
+		log.Printf("❌ Failed to close Redis: %v", err)
+		return
+	}
+	if embedded != nil {
+		close(embeddedStop)
+		embedded.Close()
+		embedded = nil
 	}
-	return ok
+	log.Println("✅ Redis connection closed")
 }
 
 // CacheTask 缓存任务数据
@@ -143,3 +186,41 @@ func BatchInvalidateCache(pattern string) error {
 
 	return nil
 }
+
+// PublishTaskCancel 广播任务取消信号，所有 Worker 实例都会收到
+func PublishTaskCancel(taskID string) error {
+	err := RDB.Publish(ctx, TaskCancelChannel, taskID).Err()
+	if err != nil {
+		log.Printf("❌ Failed to publish cancel for task %s: %v", taskID, err)
+		return err
+	}
+	log.Printf("✅ Cancel signal for task %s published", taskID)
+	return nil
+}
+
+// SubscribeTaskCancel 订阅任务取消信号，返回被取消的任务 ID，subCtx 取消后通道关闭
+func SubscribeTaskCancel(subCtx context.Context) <-chan string {
+	pubsub := RDB.Subscribe(subCtx, TaskCancelChannel)
+	ids := make(chan string)
+	go func() {
+		defer close(ids)
+		defer pubsub.Close()
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-subCtx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case ids <- msg.Payload:
+				case <-subCtx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ids
+}
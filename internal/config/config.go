@@ -1,27 +1,197 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
-    "log"
 )
 
 type Config struct {
-	DBUrl string
-	RedisAddr string
+	DBDriver    string // postgres 或 sqlite
+	DBUrl       string
+	RedisAddr   string
 	RabbitMQUrl string
-	Port string
+	Port        string
+
+	// Worker 并发设置
+	WorkerConcurrency     int            // 同时处理的任务数上限
+	WorkerPrefetch        int            // 预取数量（未确认消息上限），按队列权重分配
+	WorkerTypeConcurrency map[string]int // 按任务类型限制并发，例如 data_sync=5,email=50
+
+	// 队列与路由
+	Broker       string            // 消息队列后端：rabbitmq、redis 或 memory
+	TaskQueues   []string          // 所有命名队列，必须包含 default
+	TaskRoutes   map[string]string // 任务类型到队列的路由表，例如 email=critical,data_sync=bulk
+	WorkerQueues []QueueWeight     // 本 Worker 消费的队列及权重，例如 critical:6,default:3,bulk:1
+
+	WorkerLeaseTTL time.Duration // running 任务的租约时长，Worker 每三分之一租约发送一次心跳
+
+	ShutdownTimeout  time.Duration // 优雅停止的宽限期
+	DispatchInterval time.Duration // 检查到期任务和周期任务的间隔
+	SchedulerEnabled bool          // 是否在本进程内运行调度器
+
+	IdempotencyWindow time.Duration // 幂等键的有效期，超过后相同的键会创建新任务
+}
+
+// QueueWeight Worker 消费的队列及其权重，权重决定该队列分到的预取额度
+type QueueWeight struct {
+	Name   string
+	Weight int
 }
 
 var Cfg Config
 
 func LoadConfig() {
 	viper.SetConfigFile(".env")
+	viper.SetDefault("DB_DRIVER", "postgres")
+	viper.SetDefault("WORKER_CONCURRENCY", 10)
+	viper.SetDefault("WORKER_LEASE_TTL", "30s")
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("DISPATCH_INTERVAL", "1s")
+	viper.SetDefault("SCHEDULER_ENABLED", true)
+	viper.SetDefault("BROKER", "rabbitmq")
+	viper.SetDefault("TASK_QUEUES", "critical,default,bulk")
+	viper.SetDefault("WORKER_QUEUES", "critical:6,default:3,bulk:1")
+	viper.SetDefault("PORT", "8080")
+	viper.SetDefault("IDEMPOTENCY_WINDOW", "24h")
 	err := viper.ReadInConfig()
-	if err != nil {
+	if errors.Is(err, fs.ErrNotExist) {
+		// 没有 .env 时使用默认值，例如用 go run 启动单进程开发模式
+		log.Println("⚠️ .env not found, using defaults")
+	} else if err != nil {
 		log.Fatalf("Error reading config %v", err)
 	}
+	Cfg.DBDriver = viper.GetString("DB_DRIVER")
 	Cfg.DBUrl = viper.GetString("DATABASE_URL")
 	Cfg.RedisAddr = viper.GetString("REDIS_ADDR")
 	Cfg.RabbitMQUrl = viper.GetString("RABBITMQ_URL")
 	Cfg.Port = viper.GetString("PORT")
-}
\ No newline at end of file
+
+	Cfg.WorkerConcurrency = viper.GetInt("WORKER_CONCURRENCY")
+	if Cfg.WorkerConcurrency <= 0 {
+		log.Fatalf("WORKER_CONCURRENCY must be positive, got %d", Cfg.WorkerConcurrency)
+	}
+	// 预取数量默认是并发数的两倍，保证等待类型并发额度时不会饿死其他类型
+	Cfg.WorkerPrefetch = viper.GetInt("WORKER_PREFETCH")
+	if Cfg.WorkerPrefetch <= 0 {
+		Cfg.WorkerPrefetch = Cfg.WorkerConcurrency * 2
+	}
+	Cfg.WorkerTypeConcurrency, err = parseTypeLimits(viper.GetString("WORKER_TYPE_CONCURRENCY"))
+	if err != nil {
+		log.Fatalf("Invalid WORKER_TYPE_CONCURRENCY: %v", err)
+	}
+	Cfg.WorkerLeaseTTL = viper.GetDuration("WORKER_LEASE_TTL")
+	if Cfg.WorkerLeaseTTL <= 0 {
+		log.Fatalf("WORKER_LEASE_TTL must be positive, got %v", Cfg.WorkerLeaseTTL)
+	}
+	Cfg.Broker = viper.GetString("BROKER")
+	Cfg.TaskQueues = splitList(viper.GetString("TASK_QUEUES"))
+	if !slices.Contains(Cfg.TaskQueues, "default") {
+		log.Fatalf("TASK_QUEUES must include \"default\", got %v", Cfg.TaskQueues)
+	}
+	Cfg.TaskRoutes, err = parseRoutes(viper.GetString("TASK_ROUTES"), Cfg.TaskQueues)
+	if err != nil {
+		log.Fatalf("Invalid TASK_ROUTES: %v", err)
+	}
+	Cfg.WorkerQueues, err = ParseQueueWeights(viper.GetString("WORKER_QUEUES"), Cfg.TaskQueues)
+	if err != nil {
+		log.Fatalf("Invalid WORKER_QUEUES: %v", err)
+	}
+	Cfg.ShutdownTimeout = viper.GetDuration("SHUTDOWN_TIMEOUT")
+	Cfg.DispatchInterval = viper.GetDuration("DISPATCH_INTERVAL")
+	if Cfg.DispatchInterval <= 0 {
+		log.Fatalf("DISPATCH_INTERVAL must be positive, got %v", Cfg.DispatchInterval)
+	}
+	Cfg.SchedulerEnabled = viper.GetBool("SCHEDULER_ENABLED")
+	Cfg.IdempotencyWindow = viper.GetDuration("IDEMPOTENCY_WINDOW")
+	if Cfg.IdempotencyWindow <= 0 {
+		log.Fatalf("IDEMPOTENCY_WINDOW must be positive, got %v", Cfg.IdempotencyWindow)
+	}
+}
+
+// parseTypeLimits 解析 "type=n,type=n" 格式的配置
+func parseTypeLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected type=limit, got %q", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit for %q: %q", name, value)
+		}
+		limits[strings.TrimSpace(name)] = n
+	}
+	return limits, nil
+}
+
+// parseRoutes 解析 "type=queue,type=queue" 格式的路由表
+func parseRoutes(s string, queues []string) (map[string]string, error) {
+	routes := make(map[string]string)
+	for _, item := range splitList(s) {
+		taskType, queue, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected type=queue, got %q", item)
+		}
+		taskType, queue = strings.TrimSpace(taskType), strings.TrimSpace(queue)
+		if !slices.Contains(queues, queue) {
+			return nil, fmt.Errorf("unknown queue %q for %q", queue, taskType)
+		}
+		if _, exists := routes[taskType]; exists {
+			return nil, fmt.Errorf("duplicate route for %q", taskType)
+		}
+		routes[taskType] = queue
+	}
+	return routes, nil
+}
+
+// ParseQueueWeights 解析 "queue:weight,queue" 格式的队列列表，省略权重时为 1
+func ParseQueueWeights(s string, queues []string) ([]QueueWeight, error) {
+	var weights []QueueWeight
+	for _, item := range splitList(s) {
+		name, rawWeight, hasWeight := strings.Cut(item, ":")
+		name = strings.TrimSpace(name)
+		if !slices.Contains(queues, name) {
+			return nil, fmt.Errorf("unknown queue %q", name)
+		}
+		if slices.ContainsFunc(weights, func(w QueueWeight) bool { return w.Name == name }) {
+			return nil, fmt.Errorf("duplicate queue %q", name)
+		}
+		weight := 1
+		if hasWeight {
+			n, err := strconv.Atoi(strings.TrimSpace(rawWeight))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid weight for %q: %q", name, rawWeight)
+			}
+			weight = n
+		}
+		weights = append(weights, QueueWeight{Name: name, Weight: weight})
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("at least one queue is required")
+	}
+	return weights, nil
+}
+
+// splitList 按逗号拆分并去掉空白项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
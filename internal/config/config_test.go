@@ -0,0 +1,94 @@
+package config
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+var testQueues = []string{"critical", "default", "bulk"}
+
+func TestParseQueueWeights(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []QueueWeight
+		wantErr bool
+	}{
+		{"critical:6,default:3,bulk", []QueueWeight{{"critical", 6}, {"default", 3}, {"bulk", 1}}, false},
+		{" default : 2 , , bulk ", []QueueWeight{{"default", 2}, {"bulk", 1}}, false},
+		{"default", []QueueWeight{{"default", 1}}, false},
+		{"", nil, true},
+		{" , ", nil, true},
+		{"unknown:2", nil, true},
+		{"default:0", nil, true},
+		{"default:-1", nil, true},
+		{"default:abc", nil, true},
+		{"default:", nil, true},
+		{"default:1.5", nil, true},
+		{"default:2,default:3", nil, true},
+		{"default,bulk,default", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseQueueWeights(tt.in, testQueues)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseQueueWeights(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseQueueWeights(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"email=critical, data_sync = bulk", map[string]string{"email": "critical", "data_sync": "bulk"}, false},
+		{"email", nil, true},
+		{"email=unknown", nil, true},
+		{"email=", nil, true},
+		{"email=critical,email=bulk", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseRoutes(tt.in, testQueues)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRoutes(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && !maps.Equal(got, tt.want) {
+				t.Errorf("parseRoutes(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTypeLimits(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]int
+		wantErr bool
+	}{
+		{"", map[string]int{}, false},
+		{"email=5, data_sync = 2", map[string]int{"email": 5, "data_sync": 2}, false},
+		{"email", nil, true},
+		{"email=0", nil, true},
+		{"email=-3", nil, true},
+		{"email=many", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseTypeLimits(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTypeLimits(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && !maps.Equal(got, tt.want) {
+				t.Errorf("parseTypeLimits(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -6,24 +6,92 @@ import (
 
 	"github.com/WangZhaoye/go-task-processor/internal/config"
 	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// InitDB 按 config.Cfg.DBDriver 连接 PostgreSQL 或 SQLite，迁移表结构并创建 store.Default
 func InitDB() {
 	dsn := config.Cfg.DBUrl
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	var dialector gorm.Dialector
+	switch config.Cfg.DBDriver {
+	case "postgres":
+		dialector = postgres.Open(dsn)
+	case "sqlite":
+		dialector = sqlite.Open(dsn)
+	default:
+		log.Fatalf("Unknown DB_DRIVER %q", config.Cfg.DBDriver)
+	}
+	// TranslateError 把唯一索引冲突统一转换为 gorm.ErrDuplicatedKey
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Fatalf("Fail to connect to DB %v", err)
 	}
 
-	err = db.AutoMigrate(&model.Task{})
+	// SQLite 同一时间只允许一个写事务，单连接可以避免 database is locked，也让 :memory: 数据库在连接间共享
+	if config.Cfg.DBDriver == "sqlite" {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Fail to get DB handle %v", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if err := migrateJSONColumns(db); err != nil {
+		log.Fatalf("Failed to migrate task payload and result: %v", err)
+	}
+	err = db.AutoMigrate(&model.Task{}, &model.Schedule{}, &model.OutboxMessage{}, &model.TaskEvent{}, &model.TaskTag{}, &model.TaskType{})
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
 	DB = db
-	fmt.Println("✅ Connected to PostgreSQL and migrated schema.")
+	store.Default = store.NewGormStore(db)
+	fmt.Printf("✅ Connected to %s and migrated schema.\n", config.Cfg.DBDriver)
+}
+
+// migrateJSONColumns 把旧版本 text 类型的 payload/result 迁移为 JSON 列，只在 tasks 表还没有 summary 列时执行一次
+//
+// 旧的 payload 都是普通字符串，原样保存为 JSON 字符串，客户端读到的值不变；
+// 旧的 result 是人可读的说明，移到 summary 中，result 留空。
+func migrateJSONColumns(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&model.Task{}) || m.HasColumn(&model.Task{}, "Summary") {
+		return nil
+	}
+	fmt.Println("🔧 Migrating task payload and result to JSON columns...")
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&model.Task{}, "Summary"); err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE tasks SET summary = result").Error; err != nil {
+			return err
+		}
+		if db.Dialector.Name() == "postgres" {
+			return tx.Exec("ALTER TABLE tasks ALTER COLUMN payload TYPE jsonb USING to_jsonb(payload), " +
+				"ALTER COLUMN result TYPE jsonb USING NULL").Error
+		}
+		return tx.Exec("UPDATE tasks SET payload = json_quote(payload), result = NULL").Error
+	})
+}
+
+// Close 关闭数据库连接池
+func Close() {
+	if DB == nil {
+		return
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		log.Printf("❌ Failed to get DB handle: %v", err)
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		log.Printf("❌ Failed to close DB: %v", err)
+		return
+	}
+	fmt.Println("✅ DB connection closed")
 }
@@ -7,5 +7,23 @@ import (
 
 func RegisterRoutes(r *gin.Engine) {
 	r.POST("/tasks", service.CreateTask)
+	r.POST("/tasks/batch", service.CreateTaskBatch)
+	r.GET("/tasks", service.ListTasks)
 	r.GET("/tasks/:id", service.GetTask)
+	r.GET("/tasks/:id/history", service.GetTaskHistory)
+	r.POST("/tasks/:id/cancel", service.CancelTask)
+	r.POST("/tasks/:id/reschedule", service.RescheduleTask)
+
+	// 死信任务查看与重新入队
+	r.GET("/dead-tasks", service.ListDeadTasks)
+	r.GET("/dead-tasks/:id", service.GetDeadTask)
+	r.POST("/dead-tasks/requeue", service.RequeueAllDeadTasks)
+	r.POST("/dead-tasks/:id/requeue", service.RequeueDeadTask)
+
+	// 周期任务管理
+	r.POST("/schedules", service.CreateSchedule)
+	r.GET("/schedules", service.ListSchedules)
+	r.GET("/schedules/:id", service.GetSchedule)
+	r.PUT("/schedules/:id", service.UpdateSchedule)
+	r.DELETE("/schedules/:id", service.DeleteSchedule)
 }
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// JSON 任意 JSON 值，PostgreSQL 中存为 jsonb，SQLite 中存为 text
+//
+// 旧版本的 payload/result 是普通字符串，从数据库读到不是合法 JSON 的值时按 JSON 字符串处理。
+type JSON json.RawMessage
+
+// JSONString 把普通字符串编码为 JSON 字符串
+func JSONString(s string) JSON {
+	b, _ := json.Marshal(s)
+	return b
+}
+
+// IsNull 判断值是否为空或 JSON null
+func (j JSON) IsNull() bool {
+	return len(j) == 0 || string(j) == "null"
+}
+
+// String 返回 JSON 字符串的内容，其他类型的值返回 JSON 文本
+func (j JSON) String() string {
+	var s string
+	if len(j) > 0 && j[0] == '"' && json.Unmarshal(j, &s) == nil {
+		return s
+	}
+	return string(j)
+}
+
+// Decode 把值解码到 v
+//
+// 值是内容为 JSON 的字符串（旧客户端把 JSON 二次编码后提交）且不能直接解码到 v 时，解码字符串的内容。
+func (j JSON) Decode(v any) error {
+	err := json.Unmarshal(j, v)
+	if err == nil {
+		return nil
+	}
+	var s string
+	if json.Unmarshal(j, &s) == nil && json.Valid([]byte(s)) {
+		return json.Unmarshal([]byte(s), v)
+	}
+	return err
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *JSON) UnmarshalJSON(b []byte) error {
+	*j = append((*j)[:0], b...)
+	return nil
+}
+
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+func (j *JSON) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", src)
+	}
+	if !json.Valid(b) {
+		*j = JSONString(string(b))
+		return nil
+	}
+	*j = append(JSON(nil), b...)
+	return nil
+}
+
+func (JSON) GormDataType() string {
+	return "json"
+}
+
+func (JSON) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "text"
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMessage 待发布到消息队列的任务消息，与任务状态变更写在同一个事务中
+type OutboxMessage struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaskID    uuid.UUID  `gorm:"type:uuid;index" json:"task_id"`
+	Queue     string     `json:"queue"`
+	Priority  int        `json:"priority"`
+	Body      string     `json:"body"`                 // 任务入队时的 JSON 快照
+	Attempts  int        `json:"attempts"`             // 发布失败的次数
+	LastError string     `json:"last_error"`           // 最后一次发布失败的原因
+	SentAt    *time.Time `gorm:"index" json:"sent_at"` // 收到 broker 确认的时间，nil 表示尚未发布
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "task_outbox"
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Duration 在 JSON 中使用 "1.5s"、"2m" 这样的字符串表示，也接受以秒为单位的数字
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration: %s", string(b))
+	}
+	return nil
+}
+
+// RetryPolicy 任务失败后的重试策略，零值字段表示沿用任务类型的默认值
+type RetryPolicy struct {
+	MaxAttempts int      `json:"max_attempts,omitempty"`                    // 最多执行次数（包含第一次）
+	BaseDelay   Duration `json:"base_delay,omitempty" swaggertype:"string"` // 第一次重试前的等待时间
+	Multiplier  float64  `json:"multiplier,omitempty"`                      // 每次重试等待时间的倍数
+	MaxDelay    Duration `json:"max_delay,omitempty" swaggertype:"string"`  // 等待时间上限
+	Jitter      float64  `json:"jitter,omitempty"`                          // 随机抖动比例，0.2 表示 ±20%
+}
+
+// DefaultRetryPolicy 未注册重试策略的任务类型使用的默认策略
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   Duration(2 * time.Second),
+	Multiplier:  2,
+	MaxDelay:    Duration(5 * time.Minute),
+	Jitter:      0.2,
+}
+
+// Merge 用 override 中的非零字段覆盖当前策略
+func (p RetryPolicy) Merge(override *RetryPolicy) RetryPolicy {
+	if override == nil {
+		return p
+	}
+	if override.MaxAttempts > 0 {
+		p.MaxAttempts = override.MaxAttempts
+	}
+	if override.BaseDelay > 0 {
+		p.BaseDelay = override.BaseDelay
+	}
+	if override.Multiplier > 0 {
+		p.Multiplier = override.Multiplier
+	}
+	if override.MaxDelay > 0 {
+		p.MaxDelay = override.MaxDelay
+	}
+	if override.Jitter > 0 {
+		p.Jitter = override.Jitter
+	}
+	return p
+}
+
+// Validate 校验策略取值范围
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts must not be negative")
+	}
+	if p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("delays must not be negative")
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// Backoff 计算第 retry 次重试（从 1 开始）前的等待时间：指数增长、封顶后再加随机抖动
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	if retry < 1 {
+		retry = 1
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{
+			name:   "first retry waits base delay",
+			policy: RetryPolicy{BaseDelay: Duration(2 * time.Second), Multiplier: 2},
+			retry:  1,
+			want:   2 * time.Second,
+		},
+		{
+			name:   "grows exponentially",
+			policy: RetryPolicy{BaseDelay: Duration(2 * time.Second), Multiplier: 2},
+			retry:  4,
+			want:   16 * time.Second,
+		},
+		{
+			name:   "capped at max delay",
+			policy: RetryPolicy{BaseDelay: Duration(2 * time.Second), Multiplier: 2, MaxDelay: Duration(10 * time.Second)},
+			retry:  4,
+			want:   10 * time.Second,
+		},
+		{
+			name:   "zero max delay means no cap",
+			policy: RetryPolicy{BaseDelay: Duration(time.Second), Multiplier: 10},
+			retry:  4,
+			want:   1000 * time.Second,
+		},
+		{
+			name:   "multiplier below 1 keeps delay constant",
+			policy: RetryPolicy{BaseDelay: Duration(3 * time.Second), Multiplier: 0},
+			retry:  5,
+			want:   3 * time.Second,
+		},
+		{
+			name:   "retry below 1 treated as first retry",
+			policy: RetryPolicy{BaseDelay: Duration(time.Second), Multiplier: 3},
+			retry:  0,
+			want:   time.Second,
+		},
+		{
+			name:   "zero base delay retries immediately",
+			policy: RetryPolicy{Multiplier: 2},
+			retry:  3,
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.retry); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.retry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		retry  int
+		base   time.Duration // 不加抖动时的等待时间
+	}{
+		{
+			name:   "jitter around base delay",
+			policy: RetryPolicy{BaseDelay: Duration(10 * time.Second), Multiplier: 2, Jitter: 0.2},
+			retry:  1,
+			base:   10 * time.Second,
+		},
+		{
+			name:   "jitter applied after cap",
+			policy: RetryPolicy{BaseDelay: Duration(time.Second), Multiplier: 2, MaxDelay: Duration(5 * time.Second), Jitter: 0.5},
+			retry:  10,
+			base:   5 * time.Second,
+		},
+		{
+			name:   "default policy",
+			policy: DefaultRetryPolicy,
+			retry:  3,
+			base:   8 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spread := time.Duration(float64(tt.base) * tt.policy.Jitter)
+			for range 100 {
+				got := tt.policy.Backoff(tt.retry)
+				if got < tt.base-spread || got > tt.base+spread {
+					t.Fatalf("Backoff(%d) = %v, want within %v ± %v", tt.retry, got, tt.base, spread)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OverlapPolicy 上一次触发的任务尚未结束时的处理方式
+type OverlapPolicy string
+
+const (
+	OverlapAllow OverlapPolicy = "allow" // 照常创建新任务
+	OverlapSkip  OverlapPolicy = "skip"  // 跳过本次触发
+)
+
+// Schedule 周期任务定义，按 cron 表达式通过 CreateTask 同样的流程创建任务实例
+type Schedule struct {
+	ID              uuid.UUID     `gorm:"type:uuid;primaryKey" json:"id"`
+	Name            string        `gorm:"uniqueIndex" json:"name"`
+	CronExpr        string        `json:"cron"`
+	Timezone        string        `json:"timezone"`
+	Type            string        `json:"type"`
+	PayloadTemplate string        `json:"payload_template"` // text/template 模板，可使用 {{.ScheduledTime}} 等字段
+	Priority        int           `json:"priority"`
+	RetryPolicy     *RetryPolicy  `json:"retry_policy,omitempty" gorm:"serializer:json"`
+	OverlapPolicy   OverlapPolicy `json:"overlap_policy"`
+	Enabled         bool          `json:"enabled"`
+	NextRunAt       time.Time     `json:"next_run_at" gorm:"index"`
+	LastRunAt       *time.Time    `json:"last_run_at,omitempty"`
+	LastTaskID      *uuid.UUID    `json:"last_task_id,omitempty" gorm:"type:uuid"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,19 +10,111 @@ import (
 type TaskStatus string
 
 const (
-	StatusPending TaskStatus = "pending"
-	StatusRunning TaskStatus = "running"
-	StatusSuccess TaskStatus = "success"
-	StatusFalied  TaskStatus = "failed"
+	StatusScheduled TaskStatus = "scheduled" // 定时任务，等待 next_run_at 到期后入队
+	StatusPending   TaskStatus = "pending"
+	StatusRunning   TaskStatus = "running"
+	StatusRetrying  TaskStatus = "retrying" // 等待 next_run_at 到期后重新入队
+	StatusSuccess   TaskStatus = "success"
+	StatusFalied    TaskStatus = "failed"
+	StatusDead      TaskStatus = "dead" // 重试耗尽或无法处理，已进入死信队列
+	StatusCancelled TaskStatus = "cancelled"
+	StatusExpired   TaskStatus = "expired" // 超过 expires_at 仍未开始执行，已丢弃
+)
+
+// TaskStatuses 所有任务状态
+var TaskStatuses = []TaskStatus{
+	StatusScheduled, StatusPending, StatusRunning, StatusRetrying, StatusSuccess,
+	StatusFalied, StatusDead, StatusCancelled, StatusExpired,
+}
+
+// IsValid 判断 s 是否是已知的任务状态
+func (s TaskStatus) IsValid() bool {
+	return slices.Contains(TaskStatuses, s)
+}
+
+// IsFinished 判断任务是否已经结束（不会再被执行）
+func (s TaskStatus) IsFinished() bool {
+	switch s {
+	case StatusSuccess, StatusFalied, StatusDead, StatusCancelled, StatusExpired:
+		return true
+	}
+	return false
+}
+
+// taskTransitions 任务状态机，key 为当前状态，value 为允许转换到的状态
+//
+//	scheduled/retrying → pending → running → success/failed/retrying/dead
+//
+// 未结束的任务都可以取消或过期；running 被中断时回到 pending；死信任务可以重新入队。
+var taskTransitions = map[TaskStatus][]TaskStatus{
+	StatusScheduled: {StatusPending, StatusCancelled, StatusExpired},
+	StatusRetrying:  {StatusPending, StatusCancelled, StatusExpired},
+	StatusPending:   {StatusRunning, StatusDead, StatusCancelled, StatusExpired},
+	StatusRunning:   {StatusSuccess, StatusFalied, StatusRetrying, StatusDead, StatusPending, StatusCancelled, StatusExpired},
+	StatusDead:      {StatusPending},
+}
+
+// CanTransitionTo 判断状态机是否允许从 s 转换到 to
+func (s TaskStatus) CanTransitionTo(to TaskStatus) bool {
+	return slices.Contains(taskTransitions[s], to)
+}
+
+// TransitionSources 返回可以转换到 to 的所有状态
+func TransitionSources(to TaskStatus) []TaskStatus {
+	var sources []TaskStatus
+	for from, targets := range taskTransitions {
+		if slices.Contains(targets, to) {
+			sources = append(sources, from)
+		}
+	}
+	slices.Sort(sources)
+	return sources
+}
+
+// ErrorKind 任务最后一次失败的错误分类
+type ErrorKind string
+
+const (
+	ErrorRetryable   ErrorKind = "retryable"    // 普通错误，按重试策略重试
+	ErrorPermanent   ErrorKind = "permanent"    // 永久错误，不再重试
+	ErrorRateLimited ErrorKind = "rate_limited" // 被限流，等待后重试且不计入重试次数
+	ErrorTimedOut    ErrorKind = "timed_out"    // 执行超时，按重试策略重试
+	ErrorLeaseLost   ErrorKind = "lease_lost"   // Worker 停止心跳，租约过期后被回收，按重试策略重试
 )
 
 type Task struct {
-	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	Type       string     `json:"Type"`
-	Payload    string     `json:"payload"`
-	Status     TaskStatus `json:"status"`
-	Result     string     `json:"result"`
-	RetryCount int        `json:"retry_count" gorm:"default:0"`
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	ID          uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	Type        string       `json:"Type" gorm:"index:idx_tasks_type_status_created,priority:1"`
+	Payload     JSON         `json:"payload" swaggertype:"object"` // 任意 JSON，旧客户端提交的字符串保存为 JSON 字符串
+	Status      TaskStatus   `json:"status" gorm:"index;index:idx_tasks_type_status_created,priority:2"`
+	Priority    int          `json:"priority" gorm:"index"`                 // 0-9，数值越大越先执行
+	Queue       string       `json:"queue" gorm:"index;default:default"`    // 任务所在的命名队列
+	Result      JSON         `json:"result,omitempty" swaggertype:"object"` // Handler 通过 worker.SetResult 记录的结构化结果
+	Summary     string       `json:"summary,omitempty"`                     // 人可读的执行结果，例如失败原因
+	RetryCount  int          `json:"retry_count" gorm:"default:0"`
+	LastError   string       `json:"last_error"`
+	ErrorKind   ErrorKind    `json:"error_kind,omitempty"`
+	RetryPolicy *RetryPolicy `json:"retry_policy,omitempty" gorm:"serializer:json"`
+	NextRunAt   *time.Time   `json:"next_run_at,omitempty" gorm:"index"`
+	Timeout     Duration     `json:"timeout,omitempty" swaggertype:"string"` // 单次执行超时，0 表示使用任务类型的默认值
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`                   // 超过该时间仍未开始执行则丢弃
+	Version     int64        `json:"version" gorm:"not null;default:0"`      // 每次更新加 1，用于乐观并发控制
+
+	IdempotencyKey  *string `json:"idempotency_key,omitempty" gorm:"uniqueIndex"` // 客户端提供的幂等键，窗口期内重复提交返回同一个任务
+	IdempotencyHash string  `json:"-"`                                            // 提交请求的摘要，用于识别同一个幂等键下不同的请求
+	UniqueKey       string  `json:"unique_key,omitempty" gorm:"index"`            // 唯一任务的去重键，同类型中相同的键同一时间只有一个任务
+
+	// running 任务的租约，Worker 执行期间定期续期，过期后由调度器回收
+	WorkerID       string     `json:"worker_id,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" gorm:"index"`
+	HeartbeatAt    *time.Time `json:"heartbeat_at,omitempty"`
+
+	Tags      []string  `json:"tags,omitempty" gorm:"serializer:json"` // 按标签过滤时使用 task_tags 表
+	CreatedAt time.Time `gorm:"index;index:idx_tasks_type_status_created,priority:3"`
+	UpdatedAt time.Time `gorm:"index"`
+}
+
+// DecodePayload 把任务的 payload 解码到 v，兼容旧客户端二次编码的 JSON 字符串
+func (t *Task) DecodePayload(v any) error {
+	return t.Payload.Decode(v)
 }
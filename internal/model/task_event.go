@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaskEvent 任务状态变更历史，与状态变更写在同一个事务中
+type TaskEvent struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaskID    uuid.UUID  `gorm:"type:uuid;index" json:"task_id"`
+	From      TaskStatus `json:"from,omitempty"` // 变更前的状态，创建任务时为空
+	To        TaskStatus `json:"to"`
+	Actor     string     `json:"actor,omitempty"`   // 触发变更的一方：Worker ID、api、scheduler 或 reaper
+	Message   string     `json:"message,omitempty"` // 变更原因，例如失败时的错误信息
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (TaskEvent) TableName() string {
+	return "task_events"
+}
+
+// 不是 Worker 的变更发起方
+const (
+	ActorAPI       = "api"
+	ActorScheduler = "scheduler"
+	ActorReaper    = "reaper"
+)
@@ -0,0 +1,13 @@
+package model
+
+import "github.com/google/uuid"
+
+// TaskTag 任务标签的索引表，创建任务时与 Task.Tags 一起写入，用于按标签过滤任务
+type TaskTag struct {
+	TaskID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Tag    string    `gorm:"primaryKey;index"`
+}
+
+func (TaskTag) TableName() string {
+	return "task_tags"
+}
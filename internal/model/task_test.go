@@ -0,0 +1,50 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+// 结束的任务不会再变化，只有死信任务可以重新入队
+func TestFinishedStatusesHaveNoTransitions(t *testing.T) {
+	for _, from := range TaskStatuses {
+		if !from.IsFinished() || from == StatusDead {
+			continue
+		}
+		for _, to := range TaskStatuses {
+			if from.CanTransitionTo(to) {
+				t.Errorf("finished status %s can transition to %s", from, to)
+			}
+		}
+	}
+}
+
+// TestTransitionSources 与 CanTransitionTo 一致：返回所有可以转换到目标状态的状态
+func TestTransitionSources(t *testing.T) {
+	for _, to := range TaskStatuses {
+		t.Run(string(to), func(t *testing.T) {
+			sources := TransitionSources(to)
+			for _, from := range TaskStatuses {
+				if got, want := slices.Contains(sources, from), from.CanTransitionTo(to); got != want {
+					t.Errorf("TransitionSources(%s) contains %s = %v, CanTransitionTo = %v", to, from, got, want)
+				}
+			}
+			if !slices.IsSorted(sources) {
+				t.Errorf("TransitionSources(%s) = %v, want sorted", to, sources)
+			}
+		})
+	}
+}
+
+// TestUnknownStatusHasNoTransitions 未知状态既不能转换到其他状态，也不能由其他状态转换而来
+func TestUnknownStatusHasNoTransitions(t *testing.T) {
+	unknown := TaskStatus("unknown")
+	for _, status := range TaskStatuses {
+		if unknown.CanTransitionTo(status) || status.CanTransitionTo(unknown) {
+			t.Errorf("transition between unknown and %s allowed", status)
+		}
+	}
+	if sources := TransitionSources(unknown); len(sources) != 0 {
+		t.Errorf("TransitionSources(unknown) = %v, want none", sources)
+	}
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// TaskType Worker 注册的任务类型及其设置
+//
+// Worker 启动时把自己的注册表写入该表，API 和调度器据此校验任务类型、选择队列、
+// 计算去重时间窗口和重试策略，不需要与 Worker 编译进同一套处理器。
+type TaskType struct {
+	Name        string       `gorm:"primaryKey" json:"name"`
+	Queue       string       `json:"queue,omitempty"`                               // 默认投递的命名队列，空表示 default
+	RetryPolicy *RetryPolicy `gorm:"serializer:json" json:"retry_policy,omitempty"` // nil 表示使用 DefaultRetryPolicy
+	Timeout     Duration     `json:"timeout,omitempty" swaggertype:"string"`        // 单次执行的默认超时，0 表示不限制
+	UniqueTTL   Duration     `json:"unique_ttl,omitempty" swaggertype:"string"`     // 唯一任务的去重时间窗口，0 表示不去重
+	WorkerID    string       `json:"worker_id"`                                     // 最后一次注册该类型的 Worker
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+func (TaskType) TableName() string {
+	return "task_types"
+}
@@ -0,0 +1,164 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/config"
+)
+
+const (
+	TaskQueueName       = "task_queue" // 队列名前缀，命名队列为 task_queue.<name>
+	DefaultQueue        = "default"
+	DeadLetterQueueName = "task_dead_letter" // 死信队列
+	MaxPriority         = 9                  // 任务优先级范围 0-9，数值越大越先被消费
+)
+
+// Broker 消息队列后端，由 config.Cfg.Broker 选择
+//
+// 消息至少投递一次：Consume 得到的消息必须 Ack 或 Nack，未确认的消息在消费者断开后会重新投递。
+type Broker interface {
+	// Publish 发布消息到命名队列，返回 nil 表示 broker 已经持久化该消息
+	Publish(ctx context.Context, msg Message) error
+	// PublishBatch 发布一组消息，返回与 msgs 一一对应的错误，nil 表示该消息已经持久化
+	//
+	// 实现应该一起发送这些消息再一起等待确认，而不是逐条往返。
+	PublishBatch(ctx context.Context, msgs []Message) []error
+	// Consume 订阅命名队列，prefetch 为未确认消息的上限
+	//
+	// ctx 取消后停止订阅，已取出但还没交给调用方的消息放回队列，然后关闭返回的 channel。
+	// 连接断开时由实现自行重连并重新订阅。
+	Consume(ctx context.Context, queue string, prefetch int) (<-chan Delivery, error)
+	// RemoveDeadLetters 从死信队列中删除 match 返回 true 的消息，返回删除的条数
+	//
+	// 死信任务重新入队后调用，避免死信队列中留下已经恢复的任务。需要扫描整个死信队列。
+	RemoveDeadLetters(ctx context.Context, match func(body []byte) bool) (int, error)
+	// Close 关闭连接，未确认的消息会回到队列
+	Close() error
+}
+
+// Message 待发布的任务消息
+type Message struct {
+	Queue    string
+	Body     []byte
+	Priority int           // 0-MaxPriority，超出范围时被截断
+	Delay    time.Duration // 大于 0 时延迟投递
+}
+
+// Delivery 消费到的一条消息，处理完成后必须调用 Ack 或 Nack
+type Delivery struct {
+	Queue string
+	Body  []byte
+	acker acker
+}
+
+// acker 由各个 Broker 实现，负责确认或拒绝一条消息
+type acker interface {
+	ack() error
+	nack(requeue bool) error
+}
+
+// Ack 确认消息已处理，broker 不会再投递
+func (d Delivery) Ack() error {
+	return d.acker.ack()
+}
+
+// Nack 拒绝消息：requeue 为 true 时放回队列，否则转入死信队列
+func (d Delivery) Nack(requeue bool) error {
+	return d.acker.nack(requeue)
+}
+
+// Default 当前进程使用的 Broker，由 InitBroker 创建
+var Default Broker
+
+// InitBroker 按 config.Cfg.Broker 创建 Broker，连接失败时退出进程
+func InitBroker() {
+	var err error
+	switch config.Cfg.Broker {
+	case "rabbitmq":
+		Default, err = NewRabbitBroker(config.Cfg.RabbitMQUrl)
+	case "redis":
+		Default, err = NewRedisBroker()
+	case "memory":
+		Default = NewMemoryBroker()
+	default:
+		err = fmt.Errorf("unknown broker %q", config.Cfg.Broker)
+	}
+	if err != nil {
+		log.Fatalf("Failed to init %s broker: %v", config.Cfg.Broker, err)
+	}
+	log.Printf("✅ %s broker ready, queues %v.", config.Cfg.Broker, config.Cfg.TaskQueues)
+}
+
+// PublishTask 通过 Default 发布任务消息到命名队列
+func PublishTask(queue string, body string, priority int) error {
+	if queue == "" {
+		queue = DefaultQueue
+	}
+	if !knownQueue(queue) {
+		return fmt.Errorf("unknown queue %q", queue)
+	}
+	return Default.Publish(context.Background(), Message{
+		Queue:    queue,
+		Body:     []byte(body),
+		Priority: priority,
+	})
+}
+
+// PublishTasks 通过 Default 批量发布任务消息，返回与 msgs 一一对应的错误
+func PublishTasks(msgs []Message) []error {
+	errs := make([]error, len(msgs))
+	valid := make([]Message, 0, len(msgs))
+	indexes := make([]int, 0, len(msgs)) // valid 中每条消息在 msgs 中的位置
+	for i, msg := range msgs {
+		if msg.Queue == "" {
+			msg.Queue = DefaultQueue
+		}
+		if !knownQueue(msg.Queue) {
+			errs[i] = fmt.Errorf("unknown queue %q", msg.Queue)
+			continue
+		}
+		valid = append(valid, msg)
+		indexes = append(indexes, i)
+	}
+	if len(valid) == 0 {
+		return errs
+	}
+	for j, err := range Default.PublishBatch(context.Background(), valid) {
+		errs[indexes[j]] = err
+	}
+	return errs
+}
+
+// Close 关闭 Default
+func Close() {
+	if Default == nil {
+		return
+	}
+	if err := Default.Close(); err != nil {
+		log.Printf("❌ Failed to close broker: %v", err)
+		return
+	}
+	log.Println("✅ Broker connection closed")
+}
+
+// QueueName 返回命名队列在 broker 中的实际名称，default 队列沿用 task_queue（RabbitMQ 见 rabbitQueueName）
+func QueueName(name string) string {
+	if name == "" || name == DefaultQueue {
+		return TaskQueueName
+	}
+	return TaskQueueName + "." + name
+}
+
+// knownQueue 判断队列是否在 config.Cfg.TaskQueues 中声明过
+func knownQueue(name string) bool {
+	return slices.Contains(config.Cfg.TaskQueues, name)
+}
+
+// clampPriority 把优先级截断到 0-MaxPriority
+func clampPriority(priority int) int {
+	return min(max(priority, 0), MaxPriority)
+}
@@ -0,0 +1,214 @@
+package mq
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// errAlreadyAcked 同一条消息重复 Ack 或 Nack
+var errAlreadyAcked = errors.New("delivery already acknowledged")
+
+// MemoryBroker 进程内的 Broker，用于测试和单进程开发模式，进程退出后消息丢失
+//
+// 和 RabbitMQ 一样按优先级投递、限制每个消费者的未确认消息数，Nack 且不重新入队的消息保存在死信列表中。
+type MemoryBroker struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+	dead   []Message
+	seq    uint64
+	closed bool
+}
+
+// memoryQueue 单个命名队列，wake 在有新消息或消息被确认时关闭并替换，用于唤醒等待的消费者
+type memoryQueue struct {
+	items memoryHeap
+	wake  chan struct{}
+}
+
+type memoryItem struct {
+	msg Message
+	seq uint64 // 同优先级按发布顺序投递
+}
+
+// memoryHeap 优先级高的先出，同优先级先进先出
+type memoryHeap []memoryItem
+
+func (h memoryHeap) Len() int { return len(h) }
+func (h memoryHeap) Less(i, j int) bool {
+	if h[i].msg.Priority != h[j].msg.Priority {
+		return h[i].msg.Priority > h[j].msg.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h memoryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *memoryHeap) Push(x interface{}) { *h = append(*h, x.(memoryItem)) }
+func (h *memoryHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// NewMemoryBroker 创建一个空的进程内 Broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{queues: make(map[string]*memoryQueue)}
+}
+
+// queue 返回命名队列，不存在时创建，调用方需持有 b.mu
+func (b *MemoryBroker) queue(name string) *memoryQueue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memoryQueue{wake: make(chan struct{})}
+		b.queues[name] = q
+	}
+	return q
+}
+
+// broadcast 唤醒等待该队列的消费者，调用方需持有 b.mu
+func (q *memoryQueue) broadcast() {
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
+
+// Publish 把消息放入队列，Delay 大于 0 时到期后才放入
+func (b *MemoryBroker) Publish(ctx context.Context, msg Message) error {
+	msg.Priority = clampPriority(msg.Priority)
+	if msg.Delay > 0 {
+		delay := msg.Delay
+		msg.Delay = 0
+		time.AfterFunc(delay, func() { b.push(msg) })
+		return nil
+	}
+	b.push(msg)
+	return nil
+}
+
+// PublishBatch 逐条放入队列，进程内队列没有确认往返
+func (b *MemoryBroker) PublishBatch(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		errs[i] = b.Publish(ctx, msg)
+	}
+	return errs
+}
+
+func (b *MemoryBroker) push(msg Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.seq++
+	q := b.queue(msg.Queue)
+	heap.Push(&q.items, memoryItem{msg: msg, seq: b.seq})
+	q.broadcast()
+}
+
+// Consume 订阅命名队列，同时最多有 prefetch 条未确认消息
+func (b *MemoryBroker) Consume(ctx context.Context, queue string, prefetch int) (<-chan Delivery, error) {
+	out := make(chan Delivery)
+	inflight := 0
+	go func() {
+		defer close(out)
+		for {
+			b.mu.Lock()
+			if b.closed {
+				b.mu.Unlock()
+				return
+			}
+			q := b.queue(queue)
+			if inflight >= prefetch || q.items.Len() == 0 {
+				wake := q.wake
+				b.mu.Unlock()
+				select {
+				case <-wake:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			item := heap.Pop(&q.items).(memoryItem)
+			inflight++
+			b.mu.Unlock()
+
+			a := &memoryAcker{broker: b, msg: item.msg, release: func() { inflight-- }}
+			select {
+			case out <- Delivery{Queue: queue, Body: item.msg.Body, acker: a}:
+			case <-ctx.Done():
+				a.nack(true)
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// DeadLetters 返回被 Nack 且没有重新入队的消息
+func (b *MemoryBroker) DeadLetters() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.dead...)
+}
+
+// RemoveDeadLetters 从死信列表中删除 match 返回 true 的消息
+func (b *MemoryBroker) RemoveDeadLetters(ctx context.Context, match func(body []byte) bool) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(b.dead)
+	b.dead = slices.DeleteFunc(b.dead, func(msg Message) bool { return match(msg.Body) })
+	return n - len(b.dead), nil
+}
+
+// Close 停止所有消费者，队列中的消息被丢弃
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, q := range b.queues {
+		q.broadcast()
+	}
+	return nil
+}
+
+// memoryAcker 确认后释放消费者的预取额度，release 在持有 broker.mu 时调用
+type memoryAcker struct {
+	broker  *MemoryBroker
+	msg     Message
+	release func()
+	done    bool
+}
+
+func (a *memoryAcker) ack() error {
+	return a.finish(func(b *MemoryBroker) {})
+}
+
+func (a *memoryAcker) nack(requeue bool) error {
+	return a.finish(func(b *MemoryBroker) {
+		if !requeue {
+			b.dead = append(b.dead, a.msg)
+			return
+		}
+		b.seq++
+		heap.Push(&b.queue(a.msg.Queue).items, memoryItem{msg: a.msg, seq: b.seq})
+	})
+}
+
+func (a *memoryAcker) finish(fn func(b *MemoryBroker)) error {
+	b := a.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if a.done {
+		return errAlreadyAcked
+	}
+	a.done = true
+	a.release()
+	if !b.closed {
+		fn(b)
+	}
+	b.queue(a.msg.Queue).broadcast()
+	return nil
+}
@@ -0,0 +1,145 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// receive 从 msgs 中读取一条消息，wait 内没有消息时返回 false
+func receive(t *testing.T, msgs <-chan Delivery, wait time.Duration) (Delivery, bool) {
+	t.Helper()
+	select {
+	case d, ok := <-msgs:
+		return d, ok
+	case <-time.After(wait):
+		return Delivery{}, false
+	}
+}
+
+func consume(t *testing.T, b Broker, prefetch int) <-chan Delivery {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	msgs, err := b.Consume(ctx, DefaultQueue, prefetch)
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	return msgs
+}
+
+func TestMemoryBrokerAck(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	if err := b.Publish(context.Background(), Message{Queue: DefaultQueue, Body: []byte("a")}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	msgs := consume(t, b, 1)
+
+	d, ok := receive(t, msgs, time.Second)
+	if !ok || string(d.Body) != "a" {
+		t.Fatalf("received %q, %v, want a", d.Body, ok)
+	}
+	if err := d.Ack(); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if err := d.Ack(); !errors.Is(err, errAlreadyAcked) {
+		t.Errorf("second Ack() error = %v, want errAlreadyAcked", err)
+	}
+	if d, ok := receive(t, msgs, 50*time.Millisecond); ok {
+		t.Errorf("acked message delivered again: %q", d.Body)
+	}
+}
+
+func TestMemoryBrokerNackRequeue(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	b.Publish(context.Background(), Message{Queue: DefaultQueue, Body: []byte("a")})
+	msgs := consume(t, b, 1)
+
+	d, _ := receive(t, msgs, time.Second)
+	if err := d.Nack(true); err != nil {
+		t.Fatalf("Nack(true) error = %v", err)
+	}
+	d, ok := receive(t, msgs, time.Second)
+	if !ok || string(d.Body) != "a" {
+		t.Fatalf("requeued message not redelivered: %q, %v", d.Body, ok)
+	}
+	d.Ack()
+	if dead := b.DeadLetters(); len(dead) != 0 {
+		t.Errorf("requeued message dead-lettered: %v", dead)
+	}
+}
+
+func TestMemoryBrokerDeadLetter(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	for _, body := range []string{"a", "b"} {
+		b.Publish(context.Background(), Message{Queue: DefaultQueue, Body: []byte(body)})
+	}
+	msgs := consume(t, b, 2)
+	for range 2 {
+		d, ok := receive(t, msgs, time.Second)
+		if !ok {
+			t.Fatal("message not delivered")
+		}
+		if err := d.Nack(false); err != nil {
+			t.Fatalf("Nack(false) error = %v", err)
+		}
+	}
+	if d, ok := receive(t, msgs, 50*time.Millisecond); ok {
+		t.Errorf("dead-lettered message delivered again: %q", d.Body)
+	}
+	if dead := b.DeadLetters(); len(dead) != 2 {
+		t.Fatalf("DeadLetters() = %d messages, want 2", len(dead))
+	}
+
+	n, err := b.RemoveDeadLetters(context.Background(), func(body []byte) bool { return string(body) == "a" })
+	if err != nil || n != 1 {
+		t.Fatalf("RemoveDeadLetters() = %d, %v, want 1", n, err)
+	}
+	if dead := b.DeadLetters(); len(dead) != 1 || string(dead[0].Body) != "b" {
+		t.Errorf("DeadLetters() after removal = %v, want only b", dead)
+	}
+}
+
+// TestMemoryBrokerPrefetch 未确认的消息达到 prefetch 后不再投递，确认后继续
+func TestMemoryBrokerPrefetch(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	for _, body := range []string{"a", "b"} {
+		b.Publish(context.Background(), Message{Queue: DefaultQueue, Body: []byte(body)})
+	}
+	msgs := consume(t, b, 1)
+
+	first, _ := receive(t, msgs, time.Second)
+	if d, ok := receive(t, msgs, 50*time.Millisecond); ok {
+		t.Fatalf("second message %q delivered before the first was acked", d.Body)
+	}
+	first.Ack()
+	if _, ok := receive(t, msgs, time.Second); !ok {
+		t.Error("second message not delivered after ack")
+	}
+}
+
+func TestMemoryBrokerOrder(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	ctx := context.Background()
+	b.PublishBatch(ctx, []Message{
+		{Queue: DefaultQueue, Body: []byte("low"), Priority: 1},
+		{Queue: DefaultQueue, Body: []byte("delayed"), Priority: MaxPriority, Delay: 100 * time.Millisecond},
+		{Queue: DefaultQueue, Body: []byte("high"), Priority: MaxPriority + 5},
+		{Queue: DefaultQueue, Body: []byte("low 2"), Priority: 1},
+	})
+	msgs := consume(t, b, 10)
+
+	for _, want := range []string{"high", "low", "low 2", "delayed"} {
+		d, ok := receive(t, msgs, time.Second)
+		if !ok || string(d.Body) != want {
+			t.Fatalf("received %q, %v, want %q", d.Body, ok, want)
+		}
+		d.Ack()
+	}
+}
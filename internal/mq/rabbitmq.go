@@ -0,0 +1,699 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/config"
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// LegacyTaskQueueName 早期版本声明的 default 队列，没有死信交换机和优先级参数
+	//
+	// RabbitMQ 不允许修改已声明队列的参数，带新参数重新声明会返回 406 PRECONDITION_FAILED，
+	// 因此 default 队列改名为 task_queue.default，启动时把旧队列中的消息转移过去并删除旧队列。
+	LegacyTaskQueueName = TaskQueueName
+
+	DeadLetterExchange = "task_dlx"         // 死信交换机
+	DeadLetterTTL      = 7 * 24 * time.Hour // 死信消息保留时间，任务状态以数据库为准
+
+	PublishConfirmTimeout = 5 * time.Second  // 等待 broker 确认发布的时间
+	PublishConfirmWindow  = 256              // 批量发布时每次最多等待确认的消息数，不超过 confirms 的缓冲
+	ReconnectMinDelay     = time.Second      // 断线重连的初始等待时间
+	ReconnectMaxDelay     = 30 * time.Second // 断线重连的最大等待时间
+)
+
+// ErrNotConnected RabbitMQ 连接断开、正在重连
+var ErrNotConnected = errors.New("RabbitMQ is not connected")
+
+// rabbitBroker 基于 RabbitMQ 的 Broker，断线后在后台自动重连并重新声明队列
+//
+// 每个命名队列 task_queue.<name> 都有一个延迟队列 task_queue.<name>.delay：延迟消息带着 TTL
+// 发布到延迟队列，过期后被转回原队列。同一延迟队列中先到期的消息会被排在前面的长延迟消息挡住，
+// 长时间的延迟应该使用数据库调度（run_at）。
+type rabbitBroker struct {
+	url string
+
+	mu      sync.RWMutex
+	conn    *amqp.Connection
+	ready   chan struct{} // 连接可用时关闭，断线后换成新的 channel
+	closing bool
+
+	// 发布使用 confirm 模式的独立 channel，publishMu 保证发布和等待确认按顺序进行
+	publishMu  sync.Mutex
+	publishCh  *amqp.Channel
+	publishSeq uint64
+	confirms   chan amqp.Confirmation
+}
+
+// NewRabbitBroker 建立连接并声明队列，之后连接断开时会在后台自动重连
+func NewRabbitBroker(url string) (Broker, error) {
+	b := &rabbitBroker{url: url, ready: make(chan struct{})}
+	c, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	go b.watch(c)
+	return b, nil
+}
+
+// connect 建立连接、声明队列并打开发布用的 channel
+func (b *rabbitBroker) connect() (*amqp.Connection, error) {
+	c, err := amqp.Dial(b.url)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := c.Channel()
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	if err := declareTopology(ch); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := migrateLegacyQueues(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	// 开启 publisher confirms，broker 持久化消息后才算发布成功
+	if err := ch.Confirm(false); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	b.publishMu.Lock()
+	b.publishCh = ch
+	b.publishSeq = 0
+	b.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, PublishConfirmWindow))
+	b.publishMu.Unlock()
+
+	b.mu.Lock()
+	b.conn = c
+	close(b.ready)
+	b.mu.Unlock()
+	return c, nil
+}
+
+// watch 等待连接断开，然后按指数退避重连，直到调用 Close
+func (b *rabbitBroker) watch(c *amqp.Connection) {
+	for {
+		reason, ok := <-c.NotifyClose(make(chan *amqp.Error, 1))
+
+		b.mu.Lock()
+		if b.closing {
+			b.mu.Unlock()
+			return
+		}
+		b.conn = nil
+		b.ready = make(chan struct{})
+		b.mu.Unlock()
+		if ok {
+			log.Printf("❌ RabbitMQ connection lost: %v\n", reason)
+		}
+
+		delay := ReconnectMinDelay
+		for {
+			time.Sleep(delay)
+			if b.isClosing() {
+				return
+			}
+			next, err := b.connect()
+			if err == nil {
+				c = next
+				log.Println("✅ Reconnected to RabbitMQ")
+				break
+			}
+			log.Printf("❌ Failed to reconnect to RabbitMQ: %v\n", err)
+			delay = min(delay*2, ReconnectMaxDelay)
+		}
+	}
+}
+
+func (b *rabbitBroker) isClosing() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.closing
+}
+
+func (b *rabbitBroker) readyChan() chan struct{} {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.ready
+}
+
+// declareTopology 声明死信交换机、死信队列、所有命名队列及其延迟队列，重复声明是幂等的
+func declareTopology(ch *amqp.Channel) error {
+	// 死信交换机和队列：被 Worker 拒绝（nack 且不重新入队）的消息会路由到这里
+	err := ch.ExchangeDeclare(
+		DeadLetterExchange, // name
+		"direct",           // kind
+		true,               // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead letter exchange: %w", err)
+	}
+
+	dlq, err := ch.QueueDeclare(
+		DeadLetterQueueName, // queue name
+		true,                // durable
+		false,               // delete when unused
+		false,               // exclusive
+		false,               // no-wait
+		amqp.Table{
+			"x-message-ttl": int64(DeadLetterTTL / time.Millisecond),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead letter queue: %w", err)
+	}
+
+	// 每个命名队列都把死信转到同一个死信队列，死信消息保留原来的 routing key（队列名）
+	for _, name := range config.Cfg.TaskQueues {
+		queueName := rabbitQueueName(name)
+		err = ch.QueueBind(dlq.Name, queueName, DeadLetterExchange, false, nil)
+		if err != nil {
+			return fmt.Errorf("failed to bind dead letter queue: %w", err)
+		}
+
+		_, err := ch.QueueDeclare(
+			queueName, // queue name
+			true,      // durable
+			false,     // delete when unused
+			false,     // exclusive
+			false,     // no-wait
+			amqp.Table{
+				"x-dead-letter-exchange": DeadLetterExchange,
+				"x-max-priority":         int32(MaxPriority),
+			},
+		)
+		if err != nil {
+			return declareQueueError(queueName, err)
+		}
+
+		// 延迟队列没有消费者，消息过期后经默认交换机转回原队列
+		_, err = ch.QueueDeclare(
+			delayQueueName(name), // queue name
+			true,                 // durable
+			false,                // delete when unused
+			false,                // exclusive
+			false,                // no-wait
+			amqp.Table{
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queueName,
+			},
+		)
+		if err != nil {
+			return declareQueueError(delayQueueName(name), err)
+		}
+	}
+	return nil
+}
+
+// declareQueueError 说明队列声明失败的原因
+//
+// 优先级（x-max-priority）和死信交换机只能在创建队列时指定，已存在的同名队列参数不同时
+// RabbitMQ 返回 406 PRECONDITION_FAILED，只能把消息转移到新队列后删除旧队列再重新声明。
+func declareQueueError(queue string, err error) error {
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed {
+		return fmt.Errorf("queue %s already exists with different arguments (want x-max-priority=%d, x-dead-letter-exchange=%s); "+
+			"move its messages to another queue and delete it, see README: %w", queue, MaxPriority, DeadLetterExchange, err)
+	}
+	return fmt.Errorf("failed to declare queue %s: %w", queue, err)
+}
+
+// rabbitQueueName 返回命名队列在 RabbitMQ 中的名称，default 队列为 task_queue.default
+func rabbitQueueName(name string) string {
+	if name == "" || name == DefaultQueue {
+		return TaskQueueName + "." + DefaultQueue
+	}
+	return QueueName(name)
+}
+
+// delayQueueName 返回命名队列对应的延迟队列名称
+func delayQueueName(name string) string {
+	return rabbitQueueName(name) + ".delay"
+}
+
+// migrateLegacyQueues 把旧版本 default 队列及其延迟队列中的消息转移到新队列，然后删除旧队列
+//
+// 旧延迟队列中的消息按原来的 TTL 重新开始计时。旧版本的 Worker 或 API 仍在使用旧队列时不删除，
+// 下次启动时再次转移，所以滚动升级期间不会丢消息。
+func migrateLegacyQueues(c *amqp.Connection) error {
+	if err := migrateLegacyQueue(c, LegacyTaskQueueName, rabbitQueueName(DefaultQueue)); err != nil {
+		return err
+	}
+	// 名为 delay 的命名队列与旧的延迟队列同名，此时不能当作旧队列处理
+	if slices.Contains(config.Cfg.TaskQueues, "delay") {
+		return nil
+	}
+	return migrateLegacyQueue(c, LegacyTaskQueueName+".delay", delayQueueName(DefaultQueue))
+}
+
+// migrateLegacyQueue 把 legacy 中的消息逐条发布到 target，broker 确认后再 ack 旧消息，消息的优先级保持不变
+func migrateLegacyQueue(c *amqp.Connection, legacy, target string) error {
+	ch, err := c.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	if _, err := ch.QueueDeclarePassive(legacy, true, false, false, false, nil); err != nil {
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to inspect legacy queue %s: %w", legacy, err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	moved := 0
+	for {
+		d, ok, err := ch.Get(legacy, false)
+		if err != nil {
+			return fmt.Errorf("failed to read legacy queue %s: %w", legacy, err)
+		}
+		if !ok {
+			break
+		}
+		err = ch.Publish("", target, false, false, amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			ContentType:  d.ContentType,
+			Priority:     d.Priority,
+			Expiration:   d.Expiration,
+			Body:         d.Body,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to move message to %s: %w", target, err)
+		}
+		if confirm, ok := <-confirms; !ok || !confirm.Ack {
+			return fmt.Errorf("RabbitMQ did not confirm message moved to %s", target)
+		}
+		if err := d.Ack(false); err != nil {
+			return fmt.Errorf("failed to ack legacy message: %w", err)
+		}
+		moved++
+	}
+	if moved > 0 {
+		log.Printf("🚚 Moved %d messages from legacy queue %s to %s\n", moved, legacy, target)
+	}
+
+	if _, err := ch.QueueDelete(legacy, true, true, false); err != nil {
+		log.Printf("⚠️ Legacy queue %s is still in use, it will be migrated again on next start: %v\n", legacy, err)
+		return nil
+	}
+	log.Printf("🗑️ Deleted legacy queue %s\n", legacy)
+	return nil
+}
+
+// openChannel 在当前连接上打开新的 channel，连接断开时等待重连完成
+func (b *rabbitBroker) openChannel(ctx context.Context) (*amqp.Channel, error) {
+	for {
+		select {
+		case <-b.readyChan():
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		b.mu.RLock()
+		c := b.conn
+		b.mu.RUnlock()
+		if c != nil {
+			return c.Channel()
+		}
+	}
+}
+
+// Publish 发布消息并等待 broker 确认，连接断开期间直接返回 ErrNotConnected，由 outbox 在重连后补发
+func (b *rabbitBroker) Publish(ctx context.Context, msg Message) error {
+	return b.PublishBatch(ctx, []Message{msg})[0]
+}
+
+// PublishBatch 连续发布最多 PublishConfirmWindow 条消息后一起等待确认，而不是每条消息等待一次
+func (b *rabbitBroker) PublishBatch(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+	for start := 0; start < len(msgs); start += PublishConfirmWindow {
+		end := min(start+PublishConfirmWindow, len(msgs))
+		if err := b.publishWindow(ctx, msgs[start:end], errs[start:end]); err != nil {
+			// 连接不可用，剩余的消息不再发布
+			for i := end; i < len(msgs); i++ {
+				errs[i] = err
+			}
+			break
+		}
+	}
+	return errs
+}
+
+// publishWindow 发布一组消息并等待它们的确认，每条消息的结果记录在 errs 中；
+// 返回错误表示连接不可用。调用方持有 publishMu
+func (b *rabbitBroker) publishWindow(ctx context.Context, msgs []Message, errs []error) error {
+	select {
+	case <-b.readyChan():
+	default:
+		fill(errs, ErrNotConnected)
+		return ErrNotConnected
+	}
+
+	first := b.publishSeq + 1
+	for i, msg := range msgs {
+		routingKey, publishing := rabbitPublishing(msg)
+		err := b.publishCh.Publish(
+			"",         // exchange
+			routingKey, // routing key (queue name)
+			false,      // mandatory
+			false,      // immediate
+			publishing,
+		)
+		if err != nil {
+			// 已发布的消息仍然等待确认
+			fill(errs[i:], err)
+			if werr := b.waitConfirms(ctx, first, errs[:i]); werr != nil {
+				return werr
+			}
+			return err
+		}
+		b.publishSeq++
+	}
+	return b.waitConfirms(ctx, first, errs)
+}
+
+// fill 把 errs 中的每一项设置为 err
+func fill(errs []error, err error) {
+	for i := range errs {
+		errs[i] = err
+	}
+}
+
+// rabbitPublishing 返回消息的 routing key 和 AMQP 消息，延迟消息发布到对应的延迟队列
+func rabbitPublishing(msg Message) (string, amqp.Publishing) {
+	routingKey := rabbitQueueName(msg.Queue)
+	publishing := amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		ContentType:  "application/json",
+		Priority:     uint8(clampPriority(msg.Priority)),
+		Body:         msg.Body,
+	}
+	if msg.Delay > 0 {
+		routingKey = delayQueueName(msg.Queue)
+		publishing.Expiration = strconv.FormatInt(msg.Delay.Milliseconds(), 10)
+	}
+	return routingKey, publishing
+}
+
+// waitConfirms 等待从 first 开始的 len(errs) 个序号的发布确认，被拒绝的消息记录在 errs 中
+//
+// 之前超时未读取的确认会被跳过；超时、ctx 取消或 channel 关闭时还没有确认的消息都记录同一个错误并返回它。
+func (b *rabbitBroker) waitConfirms(ctx context.Context, first uint64, errs []error) error {
+	next := first // 下一个等待确认的序号，NotifyPublish 按序号顺序逐条返回确认
+	last := first + uint64(len(errs))
+	timeout := time.NewTimer(PublishConfirmTimeout)
+	defer timeout.Stop()
+	for next < last {
+		var err error
+		select {
+		case c, ok := <-b.confirms:
+			if !ok {
+				err = fmt.Errorf("RabbitMQ channel closed before publish was confirmed")
+				break
+			}
+			if c.DeliveryTag < first {
+				continue
+			}
+			if !c.Ack {
+				errs[c.DeliveryTag-first] = fmt.Errorf("RabbitMQ rejected published message")
+			}
+			next = c.DeliveryTag + 1
+			continue
+		case <-timeout.C:
+			err = fmt.Errorf("timed out waiting for RabbitMQ publish confirm")
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		fill(errs[next-first:], err)
+		return err
+	}
+	return nil
+}
+
+// rabbitConsumer 单个命名队列的消费者，每个队列使用独立的 channel 以便分别设置预取数量
+type rabbitConsumer struct {
+	queue    string
+	prefetch int
+	ch       *consumerChannel
+	tag      string
+	msgs     <-chan amqp.Delivery
+}
+
+// consumerChannel 消费者使用的 channel，记录已分发但还没有确认的消息
+//
+// 消息只能在投递它的 channel 上确认，停止消费后要等这些消息确认完才能关闭 channel，
+// 否则正在处理的任务确认失败，消息会被重新投递。
+type consumerChannel struct {
+	*amqp.Channel
+	mu       sync.Mutex
+	inflight int
+	closing  bool
+}
+
+// track 记录一条分发给调用方的消息
+func (c *consumerChannel) track() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight++
+}
+
+// untrack 一条消息已确认或拒绝，停止消费后最后一条消息确认时关闭 channel
+func (c *consumerChannel) untrack() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+	if c.closing && c.inflight == 0 {
+		c.close()
+	}
+}
+
+// closeWhenIdle 没有未确认的消息时立即关闭 channel，否则在最后一条消息确认后关闭
+func (c *consumerChannel) closeWhenIdle() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closing = true
+	if c.inflight == 0 {
+		c.close()
+	}
+}
+
+// close 关闭 channel，调用方持有 c.mu
+func (c *consumerChannel) close() {
+	if err := c.Channel.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		log.Printf("❌ Failed to close RabbitMQ channel: %v\n", err)
+	}
+}
+
+// Consume 订阅命名队列，channel 或连接断开时重新订阅
+//
+// 断开前已分发的消息无法再 ack，RabbitMQ 会重新投递，重复投递按任务状态跳过。
+func (b *rabbitBroker) Consume(ctx context.Context, queue string, prefetch int) (<-chan Delivery, error) {
+	c := &rabbitConsumer{queue: queue, prefetch: prefetch}
+	if err := b.subscribe(ctx, c); err != nil {
+		return nil, err
+	}
+
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case d, ok := <-c.msgs:
+				if !ok {
+					log.Printf("⚠️ RabbitMQ delivery channel for queue %s closed, resubscribing...\n", queue)
+					c.ch.closeWhenIdle()
+					if !b.resubscribe(ctx, c) {
+						return
+					}
+					log.Printf("✅ Resubscribed to queue %s\n", queue)
+					continue
+				}
+				c.ch.track()
+				select {
+				case out <- Delivery{Queue: queue, Body: d.Body, acker: &rabbitAcker{d: d, ch: c.ch}}:
+				case <-ctx.Done():
+					if err := d.Nack(false, true); err != nil {
+						log.Printf("❌ Failed to nack message: %v\n", err)
+					}
+					c.ch.untrack()
+					c.cancel()
+					return
+				}
+			case <-ctx.Done():
+				c.cancel()
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// subscribe 在新的 channel 上注册队列消费者，RabbitMQ 断线时等待重连完成
+func (b *rabbitBroker) subscribe(ctx context.Context, c *rabbitConsumer) error {
+	ch, err := b.openChannel(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open RabbitMQ channel for queue %s: %w", c.queue, err)
+	}
+
+	// 限制未确认消息数量，避免消息洪峰时一次性拉取全部消息
+	if err := ch.Qos(c.prefetch, 0, false); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to set RabbitMQ QoS for queue %s: %w", c.queue, err)
+	}
+
+	tag := "worker-" + uuid.NewString()
+	msgs, err := ch.Consume(
+		rabbitQueueName(c.queue), // 1. queue - 要消费的队列名
+		tag,                      // 2. consumer - 消费者标签，停止时用于取消订阅
+		false,                    // 3. autoAck - 手动确认，处理完成后再 ack
+		false,                    // 4. exclusive - 是否独占队列（true 表示只允许这个消费者连接）
+		false,                    // 5. noLocal - 不接收自己发送的消息（一般 RabbitMQ 不支持）
+		false,                    // 6. noWait - 是否不等待服务器响应（false 表示要等）
+		nil,                      // 7. args - 额外参数（一般 nil）
+	)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to register RabbitMQ consumer for queue %s: %w", c.queue, err)
+	}
+	c.ch, c.tag, c.msgs = &consumerChannel{Channel: ch}, tag, msgs
+	return nil
+}
+
+// resubscribe 重新注册消费者直到成功，ctx 取消时返回 false
+func (b *rabbitBroker) resubscribe(ctx context.Context, c *rabbitConsumer) bool {
+	for {
+		err := b.subscribe(ctx, c)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		log.Printf("❌ %v\n", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(ReconnectMinDelay):
+		}
+	}
+}
+
+// cancel 停止消费，已推送到本地但还没分发的消息放回队列；已分发的消息全部确认后关闭 channel
+func (c *rabbitConsumer) cancel() {
+	if err := c.ch.Cancel(c.tag, false); err != nil {
+		log.Printf("❌ Failed to cancel consumer for queue %s: %v\n", c.queue, err)
+	}
+	for d := range c.msgs {
+		if err := d.Nack(false, true); err != nil {
+			log.Printf("❌ Failed to nack message: %v\n", err)
+		}
+	}
+	c.ch.closeWhenIdle()
+}
+
+// rabbitAcker 通过消息所在的 channel 确认或拒绝消息，拒绝且不重新入队的消息进入死信队列
+type rabbitAcker struct {
+	d    amqp.Delivery
+	ch   *consumerChannel
+	done bool
+}
+
+func (a *rabbitAcker) ack() error {
+	return a.finish(func() error { return a.d.Ack(false) })
+}
+
+func (a *rabbitAcker) nack(requeue bool) error {
+	return a.finish(func() error { return a.d.Nack(false, requeue) })
+}
+
+func (a *rabbitAcker) finish(fn func() error) error {
+	if a.done {
+		return errAlreadyAcked
+	}
+	a.done = true
+	defer a.ch.untrack()
+	return fn()
+}
+
+// RemoveDeadLetters 逐条读取死信队列，确认匹配的消息，其余消息读完后一起放回队列
+//
+// 读取期间不匹配的消息保持未确认，因此不会被重复读到。
+func (b *rabbitBroker) RemoveDeadLetters(ctx context.Context, match func(body []byte) bool) (int, error) {
+	ch, err := b.openChannel(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open RabbitMQ channel: %w", err)
+	}
+	defer ch.Close()
+
+	removed := 0
+	var lastKept uint64
+	for ctx.Err() == nil {
+		d, ok, err := ch.Get(DeadLetterQueueName, false)
+		if err != nil {
+			return removed, fmt.Errorf("failed to read dead letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+		if !match(d.Body) {
+			lastKept = d.DeliveryTag
+			continue
+		}
+		if err := d.Ack(false); err != nil {
+			return removed, fmt.Errorf("failed to ack dead letter: %w", err)
+		}
+		removed++
+	}
+	if lastKept > 0 {
+		// multiple：放回所有还没确认的消息
+		if err := ch.Nack(lastKept, true, true); err != nil {
+			return removed, fmt.Errorf("failed to return dead letters: %w", err)
+		}
+	}
+	return removed, nil
+}
+
+// Close 停止重连并关闭 RabbitMQ 通道和连接，未确认的消息会回到队列
+func (b *rabbitBroker) Close() error {
+	b.mu.Lock()
+	b.closing = true
+	c := b.conn
+	b.mu.Unlock()
+
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+	if b.publishCh != nil {
+		if err := b.publishCh.Close(); err != nil {
+			log.Printf("❌ Failed to close RabbitMQ channel: %v", err)
+		}
+	}
+	if c != nil {
+		return c.Close()
+	}
+	return nil
+}
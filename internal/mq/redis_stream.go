@@ -0,0 +1,331 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/config"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	StreamKeyPrefix       = "stream:"              // 队列对应的 Redis Stream key 前缀
+	StreamConsumerGroup   = "task_workers"         // 所有 Worker 共用的消费者组
+	StreamBlockTimeout    = time.Second            // XREADGROUP 阻塞等待新消息的时间
+	StreamClaimIdle       = 30 * time.Minute       // 未确认超过该时间的消息会被其他消费者接管
+	StreamClaimInterval   = 30 * time.Second       // 检查可接管消息的间隔
+	StreamPromoteInterval = 500 * time.Millisecond // 检查到期延迟消息的间隔
+	StreamPromoteBatch    = 100                    // 每次从延迟集合转入 Stream 的最大消息数
+)
+
+// Stream 消息的字段名，死信消息额外记录来源队列
+const (
+	streamBodyField     = "body"
+	streamPriorityField = "priority"
+	streamQueueField    = "queue"
+	streamDelayedSuffix = ":delayed"
+)
+
+// promoteScript 把到期的延迟消息原子地从有序集合转入 Stream
+var promoteScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, m in ipairs(items) do
+	redis.call('ZREM', KEYS[1], m)
+	local d = cjson.decode(m)
+	redis.call('XADD', KEYS[2], '*', 'body', d.body, 'priority', d.priority)
+end
+return #items
+`)
+
+// redisBroker 基于 Redis Streams 和消费者组的 Broker，复用 internal/cache 的 Redis 连接
+//
+// 每个命名队列对应一个 Stream，消息按发布顺序投递，不支持优先级。
+// 延迟消息先放在有序集合中，到期后由后台 goroutine 转入 Stream。
+// Worker 崩溃后未确认的消息留在消费者组的 pending 列表中，超过 StreamClaimIdle 后被其他消费者接管。
+type redisBroker struct {
+	rdb      *redis.Client
+	consumer string
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRedisBroker 创建 Redis Streams Broker，需要先调用 cache.InitRedis
+func NewRedisBroker() (Broker, error) {
+	if cache.RDB == nil {
+		return nil, errors.New("redis is not initialized")
+	}
+	host, _ := os.Hostname()
+	b := &redisBroker{
+		rdb:      cache.RDB,
+		consumer: host + "-" + uuid.NewString()[:8],
+		stop:     make(chan struct{}),
+	}
+
+	ctx := context.Background()
+	for _, name := range config.Cfg.TaskQueues {
+		err := b.rdb.XGroupCreateMkStream(ctx, streamKey(name), StreamConsumerGroup, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, fmt.Errorf("failed to create consumer group for %s: %w", name, err)
+		}
+	}
+
+	b.wg.Add(1)
+	go b.promoteDelayed()
+	return b, nil
+}
+
+// streamKey 返回命名队列对应的 Stream key
+func streamKey(queue string) string {
+	return StreamKeyPrefix + QueueName(queue)
+}
+
+// delayedMessage 延迟集合中的成员，ID 保证相同内容的消息不会被合并
+type delayedMessage struct {
+	ID       string `json:"id"`
+	Body     string `json:"body"`
+	Priority int    `json:"priority"`
+}
+
+// Publish 用 XADD 追加消息，Delay 大于 0 时放入延迟集合（见 queueMessage）
+func (b *redisBroker) Publish(ctx context.Context, msg Message) error {
+	return b.PublishBatch(ctx, []Message{msg})[0]
+}
+
+// PublishBatch 用一个 pipeline 写入所有消息，只往返一次
+func (b *redisBroker) PublishBatch(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+	cmds := make([]redis.Cmder, len(msgs))
+	pipe := b.rdb.Pipeline()
+	for i, msg := range msgs {
+		cmds[i], errs[i] = queueMessage(ctx, pipe, msg)
+	}
+	// 单条命令的错误从 cmds 中读取，Exec 返回的是第一个错误
+	_, _ = pipe.Exec(ctx)
+	for i, cmd := range cmds {
+		if cmd != nil {
+			errs[i] = cmd.Err()
+		}
+	}
+	return errs
+}
+
+// queueMessage 把一条消息的写入命令加入 pipeline，延迟消息写入延迟集合
+func queueMessage(ctx context.Context, pipe redis.Pipeliner, msg Message) (redis.Cmder, error) {
+	priority := clampPriority(msg.Priority)
+	if msg.Delay > 0 {
+		member, err := json.Marshal(delayedMessage{ID: uuid.NewString(), Body: string(msg.Body), Priority: priority})
+		if err != nil {
+			return nil, err
+		}
+		return pipe.ZAdd(ctx, streamKey(msg.Queue)+streamDelayedSuffix, redis.Z{
+			Score:  float64(time.Now().Add(msg.Delay).UnixMilli()),
+			Member: member,
+		}), nil
+	}
+	return pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey(msg.Queue),
+		Values: map[string]interface{}{streamBodyField: msg.Body, streamPriorityField: priority},
+	}), nil
+}
+
+// promoteDelayed 定期把到期的延迟消息转入 Stream，直到 Close
+func (b *redisBroker) promoteDelayed() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(StreamPromoteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+		now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		for _, name := range config.Cfg.TaskQueues {
+			key := streamKey(name)
+			err := promoteScript.Run(context.Background(), b.rdb,
+				[]string{key + streamDelayedSuffix, key}, now, StreamPromoteBatch).Err()
+			if err != nil {
+				log.Printf("❌ Failed to promote delayed messages for %s: %v\n", name, err)
+			}
+		}
+	}
+}
+
+// Consume 以消费者组方式读取 Stream，同时最多有 prefetch 条未确认消息
+func (b *redisBroker) Consume(ctx context.Context, queue string, prefetch int) (<-chan Delivery, error) {
+	key := streamKey(queue)
+	slots := make(chan struct{}, prefetch)
+	out := make(chan Delivery)
+
+	go func() {
+		defer close(out)
+		lastClaim := time.Time{}
+		for {
+			// 至少有一个空闲额度才继续读取
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			free := 1
+			for free < prefetch && len(slots) < cap(slots) {
+				slots <- struct{}{}
+				free++
+			}
+
+			var msgs []redis.XMessage
+			var err error
+			if time.Since(lastClaim) >= StreamClaimInterval {
+				lastClaim = time.Now()
+				msgs, _, err = b.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+					Stream:   key,
+					Group:    StreamConsumerGroup,
+					Consumer: b.consumer,
+					MinIdle:  StreamClaimIdle,
+					Start:    "0-0",
+					Count:    int64(free),
+				}).Result()
+				if len(msgs) > 0 {
+					log.Printf("♻️ Claimed %d stale messages from %s\n", len(msgs), key)
+				}
+			}
+			if err == nil && len(msgs) == 0 {
+				var streams []redis.XStream
+				streams, err = b.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+					Group:    StreamConsumerGroup,
+					Consumer: b.consumer,
+					Streams:  []string{key, ">"},
+					Count:    int64(free),
+					Block:    StreamBlockTimeout,
+				}).Result()
+				for _, s := range streams {
+					msgs = append(msgs, s.Messages...)
+				}
+			}
+			if err != nil && !errors.Is(err, redis.Nil) {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("❌ Failed to read stream %s: %v\n", key, err)
+				time.Sleep(ReconnectMinDelay)
+			}
+
+			// 归还没有用上的额度
+			for range free - len(msgs) {
+				<-slots
+			}
+			for i, m := range msgs {
+				a := &streamAcker{broker: b, queue: queue, msg: m, slots: slots}
+				select {
+				case out <- Delivery{Queue: queue, Body: []byte(fmt.Sprint(m.Values[streamBodyField])), acker: a}:
+				case <-ctx.Done():
+					// 剩余消息放回队列
+					for _, rest := range msgs[i:] {
+						r := &streamAcker{broker: b, queue: queue, msg: rest, slots: slots}
+						if err := r.nack(true); err != nil {
+							log.Printf("❌ Failed to requeue message %s: %v\n", rest.ID, err)
+						}
+					}
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Close 停止延迟消息转移，Redis 连接由 internal/cache 关闭
+func (b *redisBroker) Close() error {
+	close(b.stop)
+	b.wg.Wait()
+	return nil
+}
+
+// RemoveDeadLetters 分页扫描死信 Stream，删除匹配的消息
+func (b *redisBroker) RemoveDeadLetters(ctx context.Context, match func(body []byte) bool) (int, error) {
+	key := StreamKeyPrefix + DeadLetterQueueName
+	removed := 0
+	start := "-"
+	for {
+		msgs, err := b.rdb.XRangeN(ctx, key, start, "+", StreamPromoteBatch).Result()
+		if err != nil {
+			return removed, fmt.Errorf("failed to read dead letter stream: %w", err)
+		}
+		var ids []string
+		for _, msg := range msgs {
+			body, _ := msg.Values[streamBodyField].(string)
+			if match([]byte(body)) {
+				ids = append(ids, msg.ID)
+			}
+		}
+		if len(ids) > 0 {
+			if err := b.rdb.XDel(ctx, key, ids...).Err(); err != nil {
+				return removed, fmt.Errorf("failed to delete dead letters: %w", err)
+			}
+			removed += len(ids)
+		}
+		if len(msgs) < StreamPromoteBatch {
+			return removed, nil
+		}
+		// 从最后一条之后继续，( 表示不包含该 ID
+		start = "(" + msgs[len(msgs)-1].ID
+	}
+}
+
+// streamAcker 确认时从消费者组的 pending 列表和 Stream 中删除消息，并归还预取额度
+type streamAcker struct {
+	broker *redisBroker
+	queue  string
+	msg    redis.XMessage
+	slots  chan struct{}
+	done   bool
+}
+
+func (a *streamAcker) ack() error {
+	return a.finish(nil)
+}
+
+// nack 重新入队时追加到 Stream 末尾，否则追加到死信 Stream
+func (a *streamAcker) nack(requeue bool) error {
+	values := map[string]interface{}{
+		streamBodyField:     a.msg.Values[streamBodyField],
+		streamPriorityField: a.msg.Values[streamPriorityField],
+	}
+	target := streamKey(a.queue)
+	if !requeue {
+		target = StreamKeyPrefix + DeadLetterQueueName
+		values[streamQueueField] = a.queue
+	}
+	return a.finish(&redis.XAddArgs{Stream: target, Values: values})
+}
+
+func (a *streamAcker) finish(republish *redis.XAddArgs) error {
+	if a.done {
+		return errAlreadyAcked
+	}
+	a.done = true
+	defer func() { <-a.slots }()
+
+	ctx := context.Background()
+	key := streamKey(a.queue)
+	_, err := a.broker.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if republish != nil {
+			pipe.XAdd(ctx, republish)
+		}
+		pipe.XAck(ctx, key, StreamConsumerGroup, a.msg.ID)
+		pipe.XDel(ctx, key, a.msg.ID)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/service"
+)
+
+// RunCron 定期检查到期的周期任务并创建任务实例，直到 ctx 被取消
+func RunCron(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("⏲️ Cron scheduler started (interval=%v)\n", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("🛑 Cron scheduler stopped")
+			return
+		case now := <-ticker.C:
+			if _, err := service.FireDueSchedules(now); err != nil {
+				log.Printf("❌ Failed to fire schedules: %v\n", err)
+			}
+		}
+	}
+}
+
+// Run 同时运行到期任务发布、周期任务调度、outbox 补发和租约回收，直到 ctx 被取消
+//
+// 多个实例可以同时运行，每个任务和每次触发都通过数据库条件更新抢占，只会执行一次。
+func Run(ctx context.Context, interval time.Duration) {
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		RunDispatcher(ctx, interval)
+	}()
+	go func() {
+		defer wg.Done()
+		RunCron(ctx, interval)
+	}()
+	go func() {
+		defer wg.Done()
+		RunOutboxRelay(ctx, interval)
+	}()
+	go func() {
+		defer wg.Done()
+		RunReaper(ctx, interval)
+	}()
+	wg.Wait()
+}
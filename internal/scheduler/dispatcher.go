@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/service"
+)
+
+// DispatchBatchSize 每轮最多发布的到期任务数
+const DispatchBatchSize = 100
+
+// RunDispatcher 定期把到期的任务重新发布到消息队列，直到 ctx 被取消
+func RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("⏲️ Dispatcher started (interval=%v)\n", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("🛑 Dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+
+		// 一轮发布满额时立即继续，尽快消化积压
+		for {
+			n, err := service.DispatchDueTasks(DispatchBatchSize)
+			if err != nil {
+				log.Printf("❌ Failed to dispatch due tasks: %v\n", err)
+				break
+			}
+			if n > 0 {
+				log.Printf("📤 Dispatched %d due tasks\n", n)
+			}
+			if n < DispatchBatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/service"
+)
+
+// RelayBatchSize 每轮最多发布的 outbox 消息数
+const RelayBatchSize = 100
+
+// RunOutboxRelay 定期发布 outbox 中尚未发布的任务消息，直到 ctx 被取消
+func RunOutboxRelay(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("⏲️ Outbox relay started (interval=%v)\n", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("🛑 Outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+
+		// 一轮发布满额时立即继续，尽快消化积压
+		for {
+			n, err := service.RelayOutbox(RelayBatchSize)
+			if n > 0 {
+				log.Printf("📤 Relayed %d outbox messages\n", n)
+			}
+			if err != nil {
+				log.Printf("❌ Failed to relay outbox: %v\n", err)
+				break
+			}
+			if n < RelayBatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/service"
+)
+
+// ReapBatchSize 每轮最多回收的租约过期任务数
+const ReapBatchSize = 100
+
+// RunReaper 定期回收租约已过期的 running 任务，直到 ctx 被取消
+func RunReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("⏲️ Lease reaper started (interval=%v)\n", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("🛑 Lease reaper stopped")
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			n, err := service.ReapExpiredLeases(ReapBatchSize)
+			if n > 0 {
+				log.Printf("🪦 Reaped %d tasks with expired leases\n", n)
+			}
+			if err != nil {
+				log.Printf("❌ Failed to reap expired leases: %v\n", err)
+				break
+			}
+			if n < ReapBatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+}
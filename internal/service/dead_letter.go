@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// errTaskNotDead 任务不存在或不处于死信状态
+var errTaskNotDead = errors.New("dead task not found")
+
+// ListDeadTasks godoc
+// @Summary List dead tasks
+// @Description List tasks that exhausted their retries or could not be processed
+// @Tags dead-letter
+// @Produce json
+// @Param type query string false "Filter by task type"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /dead-tasks [get]
+func ListDeadTasks(c *gin.Context) {
+	limit, offset, err := parsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := store.TaskFilter{
+		Statuses: []model.TaskStatus{model.StatusDead},
+		Type:     c.Query("type"),
+		Order:    store.OrderByUpdated,
+		Limit:    limit,
+		Offset:   offset,
+	}
+
+	total, err := store.Default.Count(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count dead tasks"})
+		return
+	}
+
+	tasks, err := store.Default.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list dead tasks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks, "total": total})
+}
+
+// GetDeadTask godoc
+// @Summary Get a dead task
+// @Description Get a dead task including its last error
+// @Tags dead-letter
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {object} model.Task
+// @Failure 404 {object} map[string]string
+// @Router /dead-tasks/{id} [get]
+func GetDeadTask(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task id"})
+		return
+	}
+
+	task, err := store.Default.Get(c.Request.Context(), id)
+	if err != nil || task.Status != model.StatusDead {
+		c.JSON(http.StatusNotFound, gin.H{"error": errTaskNotDead.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// RequeueDeadTask godoc
+// @Summary Requeue a dead task
+// @Description Reset a dead task to pending with a fresh retry budget and publish it again
+// @Tags dead-letter
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {object} model.Task
+// @Failure 404 {object} map[string]string
+// @Router /dead-tasks/{id}/requeue [post]
+func RequeueDeadTask(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task id"})
+		return
+	}
+
+	task, err := requeueDeadTask(id)
+	if errors.Is(err, errTaskNotDead) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	removeDeadLetters(map[uuid.UUID]bool{id: true})
+	c.JSON(http.StatusOK, task)
+}
+
+// RequeueAllDeadTasks godoc
+// @Summary Requeue all dead tasks
+// @Description Requeue every dead task, optionally only those of one type
+// @Tags dead-letter
+// @Produce json
+// @Param type query string false "Only requeue tasks of this type"
+// @Success 200 {object} map[string]interface{}
+// @Router /dead-tasks/requeue [post]
+func RequeueAllDeadTasks(c *gin.Context) {
+	dead, err := store.Default.List(c.Request.Context(), store.TaskFilter{
+		Statuses: []model.TaskStatus{model.StatusDead},
+		Type:     c.Query("type"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list dead tasks"})
+		return
+	}
+
+	requeued := make(map[uuid.UUID]bool)
+	failed := gin.H{}
+	for _, task := range dead {
+		if _, err := requeueDeadTask(task.ID); err != nil {
+			// 其他请求可能已经重新入队了该任务
+			if !errors.Is(err, errTaskNotDead) {
+				failed[task.ID.String()] = err.Error()
+			}
+			continue
+		}
+		requeued[task.ID] = true
+	}
+	removeDeadLetters(requeued)
+
+	c.JSON(http.StatusOK, gin.H{"requeued": len(requeued), "failed": failed})
+}
+
+// removeDeadLetters 从 broker 的死信队列中删除已重新入队的任务的消息，失败时只记录日志，
+// 剩下的消息在死信队列的 TTL 到期后被丢弃
+func removeDeadLetters(ids map[uuid.UUID]bool) {
+	if len(ids) == 0 || mq.Default == nil {
+		return
+	}
+	removed, err := mq.Default.RemoveDeadLetters(context.Background(), func(body []byte) bool {
+		var msg struct {
+			ID uuid.UUID `json:"id"`
+		}
+		return json.Unmarshal(body, &msg) == nil && ids[msg.ID]
+	})
+	if err != nil {
+		fmt.Printf("⚠️ Failed to remove requeued tasks from dead letter queue: %v\n", err)
+		return
+	}
+	if removed > 0 {
+		fmt.Printf("🧹 Removed %d requeued tasks from dead letter queue\n", removed)
+	}
+}
+
+// requeueDeadTask 把死信任务恢复为 pending 并重新发布，保留 last_error 便于排查
+func requeueDeadTask(id uuid.UUID) (*model.Task, error) {
+	status := model.StatusPending
+	retryCount := 0
+	var result model.JSON
+	summary := ""
+	task, err := store.Default.Update(context.Background(), id, store.TaskUpdate{
+		Status:     &status,
+		RetryCount: &retryCount,
+		Result:     &result,
+		Summary:    &summary,
+		From:       []model.TaskStatus{model.StatusDead},
+		Enqueue:    true,
+		Actor:      model.ActorAPI,
+		Reason:     "requeued from dead letter",
+	})
+	if errors.Is(err, store.ErrConflict) || errors.Is(err, store.ErrNotFound) {
+		return nil, errTaskNotDead
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to reset dead task: %w", err)
+	}
+
+	syncTaskCache(id, task.Status)
+	flushOutbox(id)
+	fmt.Printf("🔄 Dead task %s requeued\n", id)
+	return task, nil
+}
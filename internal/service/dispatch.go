@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/google/uuid"
+)
+
+// dispatchableStatuses 等待 next_run_at 到期后入队的状态
+var dispatchableStatuses = []model.TaskStatus{model.StatusScheduled, model.StatusRetrying}
+
+// DispatchDueTasks 把 next_run_at 已到期的定时任务和重试任务写入 outbox 并发布，返回发布的任务数
+//
+// 多个实例同时调度时，由 TaskStore.Lease 抢占任务，每个任务只会被发布一次。
+func DispatchDueTasks(limit int) (int, error) {
+	if err := expireOverdueTasks(limit); err != nil {
+		return 0, err
+	}
+
+	leased, err := store.Default.Lease(context.Background(), time.Now(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lease due tasks: %w", err)
+	}
+
+	ids := make([]uuid.UUID, 0, len(leased))
+	for _, task := range leased {
+		syncTaskCache(task.ID, task.Status)
+		ids = append(ids, task.ID)
+	}
+	flushOutbox(ids...)
+	return len(leased), nil
+}
+
+// expireOverdueTasks 丢弃已超过 expires_at、还在等待调度的任务
+func expireOverdueTasks(limit int) error {
+	now := time.Now()
+	overdue, err := store.Default.List(context.Background(), store.TaskFilter{
+		Statuses:      dispatchableStatuses,
+		ExpiresBefore: &now,
+		Limit:         limit,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query expired tasks: %w", err)
+	}
+	for _, task := range overdue {
+		err := ExpireTask(task.ID, task.Version)
+		if errors.Is(err, ErrTaskState) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to expire task %s: %w", task.ID, err)
+		}
+		fmt.Printf("⌛ Task %s expired before dispatch\n", task.ID)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/config"
+	"github.com/WangZhaoye/go-task-processor/internal/db"
+	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// TestMain 使用进程内的存储、队列和 Redis 运行测试，不依赖外部服务
+func TestMain(m *testing.M) {
+	config.LoadConfig()
+	config.Cfg.Broker = "memory"
+	store.Default = store.NewMemoryStore()
+	mq.Default = mq.NewMemoryBroker()
+	cache.InitEmbeddedRedis()
+	gin.SetMode(gin.TestMode)
+
+	code := m.Run()
+	mq.Close()
+	cache.Close()
+	os.Exit(code)
+}
+
+// useMemoryStore 让当前测试使用一个空的内存存储，结束后恢复
+func useMemoryStore(t *testing.T) *store.MemoryStore {
+	t.Helper()
+	previous := store.Default
+	s := store.NewMemoryStore()
+	store.Default = s
+	t.Cleanup(func() { store.Default = previous })
+	return s
+}
+
+// useSQLiteStore 让当前测试使用一个空的 SQLite 内存数据库，结束后恢复
+func useSQLiteStore(t *testing.T) store.TaskStore {
+	t.Helper()
+	previous := store.Default
+	driver, url := config.Cfg.DBDriver, config.Cfg.DBUrl
+	config.Cfg.DBDriver, config.Cfg.DBUrl = "sqlite", ":memory:"
+	db.InitDB()
+	t.Cleanup(func() {
+		db.Close()
+		store.Default = previous
+		config.Cfg.DBDriver, config.Cfg.DBUrl = driver, url
+	})
+	return store.Default
+}
+
+// testStores 依次让当前测试使用内存存储和 SQLite 上的 gorm 存储运行 fn，保证两者行为一致
+func testStores(t *testing.T, fn func(t *testing.T)) {
+	t.Run("memory", func(t *testing.T) {
+		useMemoryStore(t)
+		fn(t)
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		useSQLiteStore(t)
+		fn(t)
+	})
+}
+
+// useMemoryBroker 让当前测试使用一个空的内存队列，结束后恢复
+func useMemoryBroker(t *testing.T) *mq.MemoryBroker {
+	t.Helper()
+	previous := mq.Default
+	b := mq.NewMemoryBroker()
+	mq.Default = b
+	t.Cleanup(func() {
+		mq.Default = previous
+		b.Close()
+	})
+	return b
+}
+
+// useTaskTypes 让当前测试使用指定的任务类型校验器，结束后恢复
+func useTaskTypes(t *testing.T, checker TaskTypeChecker) {
+	t.Helper()
+	previous := taskTypes
+	taskTypes = checker
+	t.Cleanup(func() { taskTypes = previous })
+}
+
+// knownTypes 只接受列出的任务类型
+type knownTypes []string
+
+func (k knownTypes) Has(taskType string) bool {
+	return slices.Contains(k, taskType)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/google/uuid"
+)
+
+// OutboxRetention 已发布的 outbox 消息保留时间，便于排查重复投递
+const OutboxRetention = 24 * time.Hour
+
+// RelayOutbox 按写入顺序发布尚未发布的 outbox 消息，返回发布成功的消息数
+//
+// 任务状态变更和 outbox 消息一起提交或一起回滚，不会出现任务是 pending 却永远不在队列中的情况。
+// 收到 broker 确认后才标记为已发布；发布失败时保留消息，下一轮再试。
+func RelayOutbox(limit int) (int, error) {
+	ctx := context.Background()
+	n, err := store.Default.RelayOutbox(ctx, limit, nil, publishOutbox)
+	if err != nil {
+		return n, err
+	}
+
+	if err := store.Default.PurgeOutbox(ctx, time.Now().Add(-OutboxRetention)); err != nil {
+		fmt.Printf("⚠️ Failed to purge sent outbox messages: %v\n", err)
+	}
+	return n, nil
+}
+
+// publishOutbox 把一组 outbox 消息发布到各自任务所在的队列，整组消息一起等待 broker 确认
+func publishOutbox(msgs []model.OutboxMessage) []error {
+	batch := make([]mq.Message, len(msgs))
+	for i, msg := range msgs {
+		batch[i] = mq.Message{Queue: msg.Queue, Body: []byte(msg.Body), Priority: msg.Priority}
+	}
+	errs := mq.PublishTasks(batch)
+	for i, err := range errs {
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to publish task %s: %w", msgs[i].TaskID, err)
+		}
+	}
+	return errs
+}
+
+// flushOutbox 事务提交后立即尝试发布这些任务的消息，失败时由 RelayOutbox 补发
+func flushOutbox(taskIDs ...uuid.UUID) {
+	if len(taskIDs) == 0 {
+		return
+	}
+	if _, err := store.Default.RelayOutbox(context.Background(), len(taskIDs), taskIDs, publishOutbox); err != nil {
+		fmt.Printf("⚠️ Tasks left in outbox: %v\n", err)
+	}
+}
+
+// RepublishPendingTasks 为已经发布过的 pending 任务重新写入 outbox 消息，由 RelayOutbox 再次发布
+//
+// 进程内 broker 的消息随进程退出丢失，使用持久化数据库重启时需要调用，否则这些任务永远不会被执行。
+// 外部 broker 会保留未确认的消息，不需要调用。
+func RepublishPendingTasks() (int, error) {
+	n, err := store.Default.RepublishPending(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to republish pending tasks: %w", err)
+	}
+	return n, nil
+}
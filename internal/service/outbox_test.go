@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/mq"
+)
+
+// unavailableBroker 发布总是失败的 broker，模拟事务提交后、发布之前进程崩溃或 broker 不可用
+type unavailableBroker struct {
+	*mq.MemoryBroker
+}
+
+func (b unavailableBroker) PublishBatch(ctx context.Context, msgs []mq.Message) []error {
+	errs := make([]error, len(msgs))
+	for i := range errs {
+		errs[i] = errors.New("broker is unavailable")
+	}
+	return errs
+}
+
+// TestRelayOutboxRecoversUnpublished 提交后没有发布出去的任务由 RelayOutbox 补发，且只发布一次
+func TestRelayOutboxRecoversUnpublished(t *testing.T) {
+	useMemoryStore(t)
+	broker := useMemoryBroker(t)
+	mq.Default = unavailableBroker{broker}
+
+	task, err := SubmitTask(TaskRequest{Type: "email", Payload: model.JSON(`{"to":"a@example.com"}`)})
+	if err != nil {
+		t.Fatalf("SubmitTask() error = %v", err)
+	}
+	if task.Status != model.StatusPending {
+		t.Fatalf("task status = %s, want pending", task.Status)
+	}
+	if n, err := RelayOutbox(10); n != 0 || err == nil {
+		t.Fatalf("RelayOutbox() with the broker down = %d, %v, want 0 and an error", n, err)
+	}
+
+	mq.Default = broker
+	if n, err := RelayOutbox(10); n != 1 || err != nil {
+		t.Fatalf("RelayOutbox() = %d, %v, want 1", n, err)
+	}
+	if n, err := RelayOutbox(10); n != 0 || err != nil {
+		t.Errorf("second RelayOutbox() = %d, %v, want nothing left to publish", n, err)
+	}
+	if n := drainQueue(t, mq.DefaultQueue, 50*time.Millisecond); n != 1 {
+		t.Errorf("published %d messages, want 1", n)
+	}
+}
+
+// TestRepublishPendingTasks 进程内队列丢失消息后，已发布过的 pending 任务重新写入 outbox 并再次发布
+func TestRepublishPendingTasks(t *testing.T) {
+	useMemoryStore(t)
+	useMemoryBroker(t)
+	if _, err := SubmitTask(TaskRequest{Type: "email", Payload: model.JSON(`{}`)}); err != nil {
+		t.Fatalf("SubmitTask() error = %v", err)
+	}
+
+	// 模拟重启：队列中的消息随进程丢失
+	useMemoryBroker(t)
+	n, err := RepublishPendingTasks()
+	if err != nil || n != 1 {
+		t.Fatalf("RepublishPendingTasks() = %d, %v, want 1", n, err)
+	}
+	if n, err := RepublishPendingTasks(); err != nil || n != 0 {
+		t.Errorf("second RepublishPendingTasks() = %d, %v, want 0 while the message is still in the outbox", n, err)
+	}
+	if n, err := RelayOutbox(10); n != 1 || err != nil {
+		t.Fatalf("RelayOutbox() = %d, %v, want 1", n, err)
+	}
+	if n := drainQueue(t, mq.DefaultQueue, 50*time.Millisecond); n != 1 {
+		t.Errorf("published %d messages after restart, want 1", n)
+	}
+}
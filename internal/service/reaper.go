@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+)
+
+// TaskRetryPolicies 可选接口，返回任务类型的重试策略（通常是 TaskTypeCatalog）
+type TaskRetryPolicies interface {
+	RetryPolicy(taskType string) model.RetryPolicy
+}
+
+// retryPolicy 返回任务生效的重试策略，没有注册表时使用 model.DefaultRetryPolicy
+func retryPolicy(task *model.Task) model.RetryPolicy {
+	policy := model.DefaultRetryPolicy
+	if p, ok := taskTypes.(TaskRetryPolicies); ok {
+		policy = p.RetryPolicy(task.Type)
+	}
+	return policy.Merge(task.RetryPolicy)
+}
+
+// ReapExpiredLeases 回收租约已过期的 running 任务，返回回收的任务数
+//
+// Worker 崩溃或失联后停止心跳，任务按重试策略进入 retrying，重试耗尽时进入死信，
+// 回收记录在任务历史中。原来的消息即使被重新投递，也会因为版本不一致被 Worker 跳过。
+func ReapExpiredLeases(limit int) (int, error) {
+	now := time.Now()
+	expired, err := store.Default.List(context.Background(), store.TaskFilter{
+		Statuses:    []model.TaskStatus{model.StatusRunning},
+		LeaseBefore: &now,
+		Limit:       limit,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expired leases: %w", err)
+	}
+
+	reaped := 0
+	for i := range expired {
+		err := reapTask(&expired[i], now)
+		if errors.Is(err, ErrTaskState) {
+			// Worker 刚好续期或结束了任务
+			continue
+		}
+		if err != nil {
+			return reaped, fmt.Errorf("failed to reap task %s: %w", expired[i].ID, err)
+		}
+		reaped++
+	}
+	return reaped, nil
+}
+
+// reapTask 按重试策略把租约过期的任务改为 retrying 或 dead
+func reapTask(task *model.Task, now time.Time) error {
+	policy := retryPolicy(task)
+	attempts := task.RetryCount + 1
+	kind := model.ErrorLeaseLost
+	lastError := fmt.Sprintf("lease expired at %s, worker %s stopped heartbeating",
+		task.LeaseExpiresAt.Format(time.RFC3339), task.WorkerID)
+
+	// 租约条件保证 Worker 在查询之后续期成功时不会被回收
+	u := store.TaskUpdate{
+		LastError:          &lastError,
+		ErrorKind:          &kind,
+		Version:            &task.Version,
+		LeaseExpiredBefore: &now,
+		Actor:              model.ActorReaper,
+	}
+	var status model.TaskStatus
+	if attempts < policy.MaxAttempts {
+		retryCount := task.RetryCount + 1
+		runAt := now.Add(policy.Backoff(retryCount))
+		status = model.StatusRetrying
+		u.RetryCount = &retryCount
+		u.NextRunAt = &runAt
+	} else {
+		summary := fmt.Sprintf("Task failed after %d attempts. Last error: %s", attempts, lastError)
+		status = model.StatusDead
+		u.Summary = &summary
+	}
+	u.Status = &status
+
+	if _, err := transitionTask(task.ID, []model.TaskStatus{model.StatusRunning}, u); err != nil {
+		return err
+	}
+	fmt.Printf("🪦 Reaped task %s from worker %s (%s)\n", task.ID, task.WorkerID, status)
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/google/uuid"
+)
+
+// newRunningTask 创建一个由 worker-1 执行、租约在 leaseUntil 到期的 running 任务
+func newRunningTask(t *testing.T, s *store.MemoryStore, retryCount int, leaseUntil time.Time) *model.Task {
+	t.Helper()
+	task := &model.Task{
+		Type:           "email",
+		Payload:        model.JSON(`{}`),
+		Status:         model.StatusRunning,
+		RetryCount:     retryCount,
+		RetryPolicy:    &model.RetryPolicy{MaxAttempts: 3},
+		WorkerID:       "worker-1",
+		LeaseExpiresAt: &leaseUntil,
+	}
+	if err := s.Create(context.Background(), task); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return task
+}
+
+func TestReapExpiredLeases(t *testing.T) {
+	s := useMemoryStore(t)
+	now := time.Now()
+	retried := newRunningTask(t, s, 0, now.Add(-time.Minute))
+	dead := newRunningTask(t, s, 2, now.Add(-time.Minute))
+	alive := newRunningTask(t, s, 0, now.Add(time.Minute))
+
+	n, err := ReapExpiredLeases(10)
+	if err != nil || n != 2 {
+		t.Fatalf("ReapExpiredLeases() = %d, %v, want 2", n, err)
+	}
+
+	tests := []struct {
+		name       string
+		task       *model.Task
+		status     model.TaskStatus
+		retryCount int
+	}{
+		{"retries left", retried, model.StatusRetrying, 1},
+		{"retries exhausted", dead, model.StatusDead, 2},
+		{"lease not expired", alive, model.StatusRunning, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task, err := s.Get(context.Background(), tt.task.ID)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if task.Status != tt.status || task.RetryCount != tt.retryCount {
+				t.Errorf("status = %s, retry_count = %d, want %s and %d", task.Status, task.RetryCount, tt.status, tt.retryCount)
+			}
+			if tt.status == model.StatusRetrying && (task.NextRunAt == nil || task.ErrorKind != model.ErrorLeaseLost) {
+				t.Errorf("retrying task next_run_at = %v, error_kind = %s, want a retry time and %s", task.NextRunAt, task.ErrorKind, model.ErrorLeaseLost)
+			}
+
+			events, err := s.History(context.Background(), tt.task.ID)
+			if err != nil {
+				t.Fatalf("History() error = %v", err)
+			}
+			last := events[len(events)-1]
+			reaped := last.Actor == model.ActorReaper && last.From == model.StatusRunning && last.To == tt.status
+			if reaped != (tt.status != model.StatusRunning) {
+				t.Errorf("last event = %+v, reaped = %v", last, reaped)
+			}
+		})
+	}
+}
+
+// TestReapRenewedLease 查询之后 Worker 续期成功的任务不会被回收
+func TestReapRenewedLease(t *testing.T) {
+	s := useMemoryStore(t)
+	now := time.Now()
+	task := newRunningTask(t, s, 0, now.Add(-time.Second))
+
+	if _, err := s.Heartbeat(context.Background(), "worker-1", []uuid.UUID{task.ID}, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Heartbeat() error = %v", err)
+	}
+	if err := reapTask(task, now); err == nil {
+		t.Error("reapTask() succeeded for a task whose lease was renewed")
+	}
+	got, err := s.Get(context.Background(), task.ID)
+	if err != nil || got.Status != model.StatusRunning {
+		t.Errorf("task status = %v (%v), want running", got.Status, err)
+	}
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrScheduleNotFound 周期任务不存在
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// cronParser 支持标准 5 段 cron 表达式以及 @daily、@every 1h 等描述符
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ScheduleRequest 创建或修改周期任务的请求
+type ScheduleRequest struct {
+	Name            string              `json:"name" binding:"required"`
+	Cron            string              `json:"cron" binding:"required"` // 例如 "0 2 * * *" 或 "@daily"
+	Timezone        string              `json:"timezone"`                // IANA 时区，默认 UTC
+	Type            string              `json:"type" binding:"required"`
+	PayloadTemplate string              `json:"payload_template" binding:"required"` // 渲染结果是 JSON 时作为 JSON payload，否则作为 JSON 字符串
+	Priority        int                 `json:"priority"`
+	RetryPolicy     *model.RetryPolicy  `json:"retry_policy"`
+	OverlapPolicy   model.OverlapPolicy `json:"overlap_policy"` // allow 或 skip，默认 skip
+	Enabled         *bool               `json:"enabled"`        // 默认 true
+}
+
+// ScheduleTemplateData 渲染 payload 模板时可用的字段
+type ScheduleTemplateData struct {
+	ScheduleID    uuid.UUID
+	ScheduleName  string
+	ScheduledTime time.Time // 本次触发的计划时间（已转换到周期任务的时区）
+}
+
+// CreateSchedule godoc
+// @Summary Create a recurring task
+// @Description Define a task that is created on a cron schedule
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param schedule body ScheduleRequest true "Schedule"
+// @Success 201 {object} model.Schedule
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /schedules [post]
+func CreateSchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := model.Schedule{ID: uuid.New()}
+	if err := applyScheduleRequest(&schedule, req); err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	if err := store.Default.CreateSchedule(c.Request.Context(), &schedule); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	fmt.Printf("✅ Schedule %s (%s) created, next run at %s\n", schedule.Name, schedule.CronExpr, schedule.NextRunAt.Format(time.RFC3339))
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// ListSchedules godoc
+// @Summary List recurring tasks
+// @Tags schedules
+// @Produce json
+// @Success 200 {array} model.Schedule
+// @Router /schedules [get]
+func ListSchedules(c *gin.Context) {
+	schedules, err := store.Default.ListSchedules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list schedules"})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// GetSchedule godoc
+// @Summary Get a recurring task
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} model.Schedule
+// @Failure 404 {object} map[string]string
+// @Router /schedules/{id} [get]
+func GetSchedule(c *gin.Context) {
+	schedule, err := loadSchedule(c.Param("id"))
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// UpdateSchedule godoc
+// @Summary Update a recurring task
+// @Description Replace a schedule definition; the next run time is recalculated
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Param schedule body ScheduleRequest true "Schedule"
+// @Success 200 {object} model.Schedule
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /schedules/{id} [put]
+func UpdateSchedule(c *gin.Context) {
+	schedule, err := loadSchedule(c.Param("id"))
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := applyScheduleRequest(schedule, req); err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	if err := store.Default.UpdateSchedule(c.Request.Context(), schedule); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteSchedule godoc
+// @Summary Delete a recurring task
+// @Description Delete a schedule; tasks already created by it are not affected
+// @Tags schedules
+// @Param id path string true "Schedule ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /schedules/{id} [delete]
+func DeleteSchedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule id"})
+		return
+	}
+	if err := store.Default.DeleteSchedule(c.Request.Context(), id); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// applyScheduleRequest 校验请求并写入 schedule，同时重新计算下一次触发时间
+func applyScheduleRequest(schedule *model.Schedule, req ScheduleRequest) error {
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timezone: %v", ErrInvalidTask, err)
+	}
+	sched, err := cronParser.Parse(req.Cron)
+	if err != nil {
+		return fmt.Errorf("%w: invalid cron expression: %v", ErrInvalidTask, err)
+	}
+	if _, err := template.New("payload").Parse(req.PayloadTemplate); err != nil {
+		return fmt.Errorf("%w: invalid payload_template: %v", ErrInvalidTask, err)
+	}
+	if taskTypes != nil && !taskTypes.Has(req.Type) {
+		return fmt.Errorf("%w: unknown task type: %s (no worker has registered it)", ErrInvalidTask, req.Type)
+	}
+	if req.RetryPolicy != nil {
+		if err := req.RetryPolicy.Validate(); err != nil {
+			return fmt.Errorf("%w: invalid retry_policy: %v", ErrInvalidTask, err)
+		}
+	}
+	if req.Priority < 0 || req.Priority > mq.MaxPriority {
+		return fmt.Errorf("%w: priority must be between 0 and %d", ErrInvalidTask, mq.MaxPriority)
+	}
+	switch req.OverlapPolicy {
+	case "":
+		req.OverlapPolicy = model.OverlapSkip
+	case model.OverlapAllow, model.OverlapSkip:
+	default:
+		return fmt.Errorf("%w: overlap_policy must be %q or %q", ErrInvalidTask, model.OverlapAllow, model.OverlapSkip)
+	}
+
+	schedule.Name = req.Name
+	schedule.CronExpr = req.Cron
+	schedule.Timezone = req.Timezone
+	schedule.Type = req.Type
+	schedule.PayloadTemplate = req.PayloadTemplate
+	schedule.Priority = req.Priority
+	schedule.RetryPolicy = req.RetryPolicy
+	schedule.OverlapPolicy = req.OverlapPolicy
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+	// 按周期任务的时区计算，保存为 UTC
+	schedule.NextRunAt = sched.Next(time.Now().In(loc)).UTC()
+	return nil
+}
+
+func loadSchedule(rawID string) (*model.Schedule, error) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return nil, ErrScheduleNotFound
+	}
+	return store.Default.GetSchedule(context.Background(), id)
+}
+
+func respondScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrScheduleNotFound), errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": ErrScheduleNotFound.Error()})
+	case errors.Is(err, store.ErrNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("❌ Schedule operation failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
+	}
+}
+
+// FireDueSchedules 为所有到期的周期任务创建任务实例，返回创建的任务数
+//
+// 每个周期任务通过比较 next_run_at 的条件更新抢占本次触发，并在同一事务中创建任务，
+// 多个 API/Worker 实例同时运行调度器时，同一次触发只会被一个实例执行；创建失败时下一轮重新触发。
+func FireDueSchedules(now time.Time) (int, error) {
+	due, err := store.Default.DueSchedules(context.Background(), now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query due schedules: %w", err)
+	}
+
+	fired := 0
+	for i := range due {
+		ok, err := fireSchedule(&due[i], now)
+		if err != nil {
+			fmt.Printf("❌ Failed to fire schedule %s: %v\n", due[i].Name, err)
+			continue
+		}
+		if ok {
+			fired++
+		}
+	}
+	return fired, nil
+}
+
+// fireSchedule 抢占并执行一次触发；错过的多次触发只补执行一次
+func fireSchedule(schedule *model.Schedule, now time.Time) (bool, error) {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return false, fmt.Errorf("invalid timezone: %w", err)
+	}
+	sched, err := cronParser.Parse(schedule.CronExpr)
+	if err != nil {
+		return false, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	run := store.ScheduleRun{
+		ScheduleID:    schedule.ID,
+		ScheduledTime: schedule.NextRunAt,
+		NextRunAt:     sched.Next(now.In(loc)).UTC(),
+	}
+
+	if schedule.OverlapPolicy == model.OverlapSkip && schedule.LastTaskID != nil {
+		last, err := store.Default.Get(context.Background(), *schedule.LastTaskID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return false, fmt.Errorf("failed to load previous task: %w", err)
+		}
+		if err == nil && !last.Status.IsFinished() {
+			if err := skipScheduleRun(run); err != nil {
+				return false, err
+			}
+			fmt.Printf("⏭️ Schedule %s skipped: previous task %s is still %s\n", schedule.Name, *schedule.LastTaskID, last.Status)
+			return false, nil
+		}
+	}
+
+	payload, err := renderPayload(schedule, run.ScheduledTime.In(loc))
+	if err != nil {
+		// 模板对同样的数据总是渲染失败，跳过本次触发，避免每轮调度都重试
+		if err := skipScheduleRun(run); err != nil {
+			fmt.Printf("⚠️ Failed to skip schedule %s: %v\n", schedule.Name, err)
+		}
+		return false, err
+	}
+
+	// 抢占和创建任务在同一个事务中，任务没有创建成功时抢占一起回滚，下一轮调度重新触发
+	task, created, err := submitTaskWith(TaskRequest{
+		Type:        schedule.Type,
+		Payload:     schedulePayload(payload),
+		Priority:    schedule.Priority,
+		RetryPolicy: schedule.RetryPolicy,
+	}, func(ctx context.Context, task *model.Task) (*model.Task, error) {
+		run.Task = task
+		return nil, store.Default.FireSchedule(ctx, run)
+	})
+	if errors.Is(err, store.ErrConflict) {
+		// 已被其他实例触发
+		return false, nil
+	}
+	if errors.Is(err, ErrInvalidTask) {
+		// 校验失败（例如任务类型已不再注册）在下一轮调度也不会恢复，跳过本次触发
+		if err := skipScheduleRun(run); err != nil {
+			fmt.Printf("⚠️ Failed to skip schedule %s: %v\n", schedule.Name, err)
+		}
+		return false, fmt.Errorf("schedule run skipped: %w", err)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to submit task: %w", err)
+	}
+	if !created {
+		// 唯一任务命中了尚未结束的任务，本次触发沿用该任务
+		run.Task, run.LastTaskID = nil, &task.ID
+		if err := skipScheduleRun(run); err != nil {
+			return false, err
+		}
+		fmt.Printf("♻️ Schedule %s matched unfinished task %s\n", schedule.Name, task.ID)
+		return false, nil
+	}
+	fmt.Printf("⏰ Schedule %s fired task %s\n", schedule.Name, task.ID)
+	return true, nil
+}
+
+// skipScheduleRun 只推进触发时间而不创建任务，本次触发已被其他实例抢占时不算错误
+func skipScheduleRun(run store.ScheduleRun) error {
+	err := store.Default.FireSchedule(context.Background(), run)
+	if err != nil && !errors.Is(err, store.ErrConflict) {
+		return fmt.Errorf("failed to claim schedule: %w", err)
+	}
+	return nil
+}
+
+// schedulePayload 渲染结果是 JSON 时按 JSON 保存，否则保存为 JSON 字符串
+func schedulePayload(rendered string) model.JSON {
+	if payload := model.JSON(bytes.TrimSpace([]byte(rendered))); json.Valid(payload) && !payload.IsNull() {
+		return payload
+	}
+	return model.JSONString(rendered)
+}
+
+func renderPayload(schedule *model.Schedule, scheduledTime time.Time) (string, error) {
+	tmpl, err := template.New("payload").Parse(schedule.PayloadTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid payload_template: %w", err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, ScheduleTemplateData{
+		ScheduleID:    schedule.ID,
+		ScheduleName:  schedule.Name,
+		ScheduledTime: scheduledTime,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render payload: %w", err)
+	}
+	return buf.String(), nil
+}
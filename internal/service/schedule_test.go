@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/google/uuid"
+)
+
+// newDueSchedule 保存一个已经到期 overdue 的周期任务
+func newDueSchedule(t *testing.T, req ScheduleRequest, overdue time.Duration) *model.Schedule {
+	t.Helper()
+	schedule := &model.Schedule{ID: uuid.New()}
+	if err := applyScheduleRequest(schedule, req); err != nil {
+		t.Fatalf("applyScheduleRequest() error = %v", err)
+	}
+	schedule.NextRunAt = time.Now().Add(-overdue).UTC().Truncate(time.Second)
+	if err := store.Default.CreateSchedule(context.Background(), schedule); err != nil {
+		t.Fatalf("CreateSchedule() error = %v", err)
+	}
+	return schedule
+}
+
+func getSchedule(t *testing.T, id uuid.UUID) *model.Schedule {
+	t.Helper()
+	schedule, err := store.Default.GetSchedule(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetSchedule() error = %v", err)
+	}
+	return schedule
+}
+
+func countTasks(t *testing.T, taskType string) int64 {
+	t.Helper()
+	n, err := store.Default.Count(context.Background(), store.TaskFilter{Type: taskType})
+	if err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	return n
+}
+
+func TestApplyScheduleRequestStoresUTC(t *testing.T) {
+	schedule := &model.Schedule{}
+	err := applyScheduleRequest(schedule, ScheduleRequest{
+		Name: "report", Cron: "0 2 * * *", Timezone: "Asia/Shanghai", Type: "email", PayloadTemplate: "{}",
+	})
+	if err != nil {
+		t.Fatalf("applyScheduleRequest() error = %v", err)
+	}
+	if schedule.NextRunAt.Location() != time.UTC {
+		t.Errorf("next_run_at location = %s, want UTC", schedule.NextRunAt.Location())
+	}
+	// 上海时间 02:00 即 UTC 18:00
+	if h := schedule.NextRunAt.Hour(); h != 18 {
+		t.Errorf("next_run_at = %s, want 18:00 UTC", schedule.NextRunAt)
+	}
+}
+
+func TestFireDueSchedules(t *testing.T) {
+	useMemoryStore(t)
+	schedule := newDueSchedule(t, ScheduleRequest{
+		Name: "sync", Cron: "@every 1m", Timezone: "Asia/Shanghai", Type: "schedule_fire_test",
+		PayloadTemplate: `{"schedule":"{{.ScheduleName}}"}`,
+	}, 5*time.Minute)
+
+	now := time.Now()
+	fired, err := FireDueSchedules(now)
+	if err != nil || fired != 1 {
+		t.Fatalf("FireDueSchedules() = %d, %v, want 1", fired, err)
+	}
+	after := getSchedule(t, schedule.ID)
+	if !after.NextRunAt.After(now) || after.LastTaskID == nil || after.LastRunAt == nil || !after.LastRunAt.Equal(schedule.NextRunAt) {
+		t.Errorf("schedule after firing = next %s, last run %v, last task %v", after.NextRunAt, after.LastRunAt, after.LastTaskID)
+	}
+	task, err := store.Default.Get(context.Background(), *after.LastTaskID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := string(task.Payload); got != `{"schedule":"sync"}` {
+		t.Errorf("payload = %s, want rendered JSON", got)
+	}
+
+	// 错过的多次触发只补执行一次
+	if fired, _ := FireDueSchedules(now); fired != 0 {
+		t.Errorf("second FireDueSchedules() = %d, want 0", fired)
+	}
+	if n := countTasks(t, "schedule_fire_test"); n != 1 {
+		t.Errorf("created %d tasks, want 1", n)
+	}
+}
+
+func TestFireScheduleOverlap(t *testing.T) {
+	tests := []struct {
+		policy    model.OverlapPolicy
+		lastState model.TaskStatus
+		wantFired bool
+	}{
+		{model.OverlapSkip, model.StatusPending, false},
+		{model.OverlapSkip, model.StatusRunning, false},
+		{model.OverlapSkip, model.StatusSuccess, true},
+		{model.OverlapAllow, model.StatusRunning, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy)+"/"+string(tt.lastState), func(t *testing.T) {
+			useMemoryStore(t)
+			ctx := context.Background()
+			last := &model.Task{Type: "overlap_test", Payload: model.JSON(`{}`), Status: tt.lastState}
+			if err := store.Default.Create(ctx, last); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			schedule := newDueSchedule(t, ScheduleRequest{
+				Name: "overlap", Cron: "@every 1m", Type: "overlap_test", PayloadTemplate: "{}", OverlapPolicy: tt.policy,
+			}, time.Minute)
+			schedule.LastTaskID = &last.ID
+			if err := store.Default.UpdateSchedule(ctx, schedule); err != nil {
+				t.Fatalf("UpdateSchedule() error = %v", err)
+			}
+
+			now := time.Now()
+			fired, err := FireDueSchedules(now)
+			if err != nil {
+				t.Fatalf("FireDueSchedules() error = %v", err)
+			}
+			if got := fired == 1; got != tt.wantFired {
+				t.Errorf("fired = %d, want fired %v", fired, tt.wantFired)
+			}
+			// 跳过时也推进触发时间，不会在下一轮重试
+			if after := getSchedule(t, schedule.ID); !after.NextRunAt.After(now) {
+				t.Errorf("next_run_at = %s was not advanced", after.NextRunAt)
+			}
+			wantTasks := int64(1)
+			if tt.wantFired {
+				wantTasks = 2
+			}
+			if n := countTasks(t, "overlap_test"); n != wantTasks {
+				t.Errorf("have %d tasks, want %d", n, wantTasks)
+			}
+		})
+	}
+}
+
+// TestFireScheduleClaim 两个调度器实例读到同一次到期触发时只有一个能创建任务
+func TestFireScheduleClaim(t *testing.T) {
+	useMemoryStore(t)
+	schedule := newDueSchedule(t, ScheduleRequest{
+		Name: "claim", Cron: "@every 1m", Type: "claim_test", PayloadTemplate: "{}", OverlapPolicy: model.OverlapAllow,
+	}, time.Minute)
+
+	now := time.Now()
+	first, second := *schedule, *schedule
+	fired, err := fireSchedule(&first, now)
+	if err != nil || !fired {
+		t.Fatalf("first fireSchedule() = %v, %v, want fired", fired, err)
+	}
+	fired, err = fireSchedule(&second, now)
+	if err != nil || fired {
+		t.Fatalf("second fireSchedule() = %v, %v, want not fired", fired, err)
+	}
+	if n := countTasks(t, "claim_test"); n != 1 {
+		t.Errorf("created %d tasks, want 1", n)
+	}
+}
+
+// TestFireScheduleInvalidTask 校验失败的触发被跳过，不会在每一轮调度中重复失败
+func TestFireScheduleInvalidTask(t *testing.T) {
+	useMemoryStore(t)
+	schedule := newDueSchedule(t, ScheduleRequest{
+		Name: "removed", Cron: "@every 1m", Type: "removed_type", PayloadTemplate: "{}",
+	}, time.Minute)
+	useTaskTypes(t, knownTypes{"email"})
+
+	now := time.Now()
+	fired, err := fireSchedule(schedule, now)
+	if fired || !errors.Is(err, ErrInvalidTask) {
+		t.Fatalf("fireSchedule() = %v, %v, want ErrInvalidTask", fired, err)
+	}
+	if after := getSchedule(t, schedule.ID); !after.NextRunAt.After(now) || after.LastTaskID != nil {
+		t.Errorf("schedule after skipped run = next %s, last task %v", after.NextRunAt, after.LastTaskID)
+	}
+	if fired, _ := FireDueSchedules(now); fired != 0 {
+		t.Errorf("FireDueSchedules() = %d after skipped run, want 0", fired)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RescheduleRequest 修改定时任务的执行时间，run_at 和 delay 二选一
+type RescheduleRequest struct {
+	RunAt *time.Time      `json:"run_at"`
+	Delay *model.Duration `json:"delay" swaggertype:"string"`
+}
+
+// cancellableStatuses 可以被取消的任务状态
+var cancellableStatuses = []model.TaskStatus{
+	model.StatusScheduled,
+	model.StatusPending,
+	model.StatusRetrying,
+	model.StatusRunning,
+}
+
+// CancelTask godoc
+// @Summary Cancel a task
+// @Description Cancel a task that has not finished. Pending tasks are skipped by the worker;
+// @Description running tasks are signalled through their handler context.
+// @Tags tasks
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {object} model.Task
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /tasks/{id}/cancel [post]
+func CancelTask(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task id"})
+		return
+	}
+
+	task, err := cancelTask(id)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// cancelTask 把未结束的任务标记为 cancelled；任务正在执行时通过 Redis 广播取消信号
+func cancelTask(id uuid.UUID) (*model.Task, error) {
+	current, err := store.Default.Get(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load task: %w", err)
+	}
+	if !slices.Contains(cancellableStatuses, current.Status) {
+		return nil, fmt.Errorf("%w: task is %s", ErrTaskState, current.Status)
+	}
+
+	// 只从读到的状态转换，状态在此期间变化时返回冲突，由调用方重试
+	cancelled := model.StatusCancelled
+	task, err := transitionTask(id, []model.TaskStatus{current.Status}, store.TaskUpdate{
+		Status:         &cancelled,
+		ClearNextRunAt: true,
+		Actor:          model.ActorAPI,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Status == model.StatusRunning {
+		if err := cache.PublishTaskCancel(id.String()); err != nil {
+			// 信号丢失时任务会执行完，但 Worker 不会覆盖 cancelled 状态
+			fmt.Printf("⚠️ Failed to signal running task %s: %v\n", id, err)
+		}
+	}
+	fmt.Printf("🚫 Task %s cancelled (was %s)\n", id, current.Status)
+	return task, nil
+}
+
+// RescheduleTask godoc
+// @Summary Reschedule a task
+// @Description Change the run time of a scheduled task that has not started yet
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param schedule body RescheduleRequest true "New run time"
+// @Success 200 {object} model.Task
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /tasks/{id}/reschedule [post]
+func RescheduleTask(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task id"})
+		return
+	}
+
+	var req RescheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	runAt, err := resolveRunAt(req.RunAt, req.Delay)
+	if err == nil && runAt == nil {
+		err = fmt.Errorf("%w: run_at or delay is required", ErrInvalidTask)
+	}
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	task, err := rescheduleTask(id, *runAt)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	fmt.Printf("⏰ Task %s rescheduled to %s\n", id, runAt.Format(time.RFC3339))
+	c.JSON(http.StatusOK, task)
+}
+
+// rescheduleTask 修改 scheduled 任务的执行时间，新时间不能晚于任务的 expires_at
+//
+// 新时间已过时，调度器会在下一轮立即发布。
+func rescheduleTask(id uuid.UUID, runAt time.Time) (*model.Task, error) {
+	current, err := store.Default.Get(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load task: %w", err)
+	}
+	if err := checkExpiresAt(current.ExpiresAt, &runAt); err != nil {
+		return nil, err
+	}
+
+	// 按读到的版本更新，expires_at 检查之后任务被修改时返回冲突
+	return transitionTask(id, []model.TaskStatus{model.StatusScheduled}, store.TaskUpdate{
+		NextRunAt: &runAt,
+		Version:   &current.Version,
+	})
+}
+
+// transitionTask 仅当任务处于 from 中的某个状态时才更新，返回更新后的任务
+func transitionTask(id uuid.UUID, from []model.TaskStatus, u store.TaskUpdate) (*model.Task, error) {
+	u.From = from
+	task, err := store.Default.Update(context.Background(), id, u)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, ErrTaskNotFound
+	case errors.Is(err, store.ErrConflict):
+		return nil, newTransitionError(task, u)
+	case err != nil:
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+
+	syncTaskCache(id, task.Status)
+	return task, nil
+}
+
+// respondTaskError 按错误类型返回对应的 HTTP 状态码和错误信息
+func respondTaskError(c *gin.Context, err error) {
+	c.JSON(taskErrorStatus(err), gin.H{"error": err.Error()})
+}
+
+// taskErrorStatus 返回任务操作错误对应的 HTTP 状态码
+func taskErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidTask):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrTaskNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrTaskState), errors.Is(err, ErrIdempotencyConflict), errors.Is(err, ErrUniqueConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
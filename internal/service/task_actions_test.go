@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+)
+
+func TestResolveRunAt(t *testing.T) {
+	shanghai := time.FixedZone("UTC+8", 8*3600)
+	runAt := time.Date(2026, 5, 1, 8, 0, 0, 0, shanghai)
+	delay := model.Duration(time.Minute)
+	negative := model.Duration(-time.Minute)
+
+	tests := []struct {
+		name    string
+		runAt   *time.Time
+		delay   *model.Duration
+		want    *time.Time
+		wantErr bool
+	}{
+		{name: "neither", want: nil},
+		{name: "run_at converted to UTC", runAt: &runAt, want: &runAt},
+		{name: "both", runAt: &runAt, delay: &delay, wantErr: true},
+		{name: "negative delay", delay: &negative, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveRunAt(tt.runAt, tt.delay)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTask) {
+					t.Fatalf("resolveRunAt() error = %v, want ErrInvalidTask", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveRunAt() error = %v", err)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && (!got.Equal(*tt.want) || got.Location() != time.UTC)) {
+				t.Errorf("resolveRunAt() = %v, want %v in UTC", got, tt.want)
+			}
+		})
+	}
+
+	got, err := resolveRunAt(nil, &delay)
+	if err != nil || got.Location() != time.UTC || time.Until(*got) <= 0 {
+		t.Errorf("resolveRunAt(delay) = %v, %v, want a UTC time in the future", got, err)
+	}
+}
+
+func TestRescheduleTask(t *testing.T) {
+	newYork := time.FixedZone("UTC-5", -5*3600)
+	now := time.Now()
+	expiresAt := now.Add(time.Hour).UTC()
+
+	tests := []struct {
+		name    string
+		runAt   time.Time
+		wantErr error
+	}{
+		{"before expires_at", now.Add(30 * time.Minute).In(newYork), nil},
+		{"at expires_at", expiresAt.In(newYork), ErrInvalidTask},
+		{"after expires_at", now.Add(2 * time.Hour), ErrInvalidTask},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemoryStore(t)
+			nextRunAt := now.Add(10 * time.Minute)
+			task := &model.Task{Type: "email", Payload: model.JSON(`{}`), Status: model.StatusScheduled, NextRunAt: &nextRunAt, ExpiresAt: &expiresAt}
+			if err := store.Default.Create(context.Background(), task); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			runAt, err := resolveRunAt(&tt.runAt, nil)
+			if err != nil {
+				t.Fatalf("resolveRunAt() error = %v", err)
+			}
+			got, err := rescheduleTask(task.ID, *runAt)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("rescheduleTask() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("rescheduleTask() error = %v", err)
+			}
+			if !got.NextRunAt.Equal(tt.runAt) || got.NextRunAt.Location() != time.UTC {
+				t.Errorf("next_run_at = %s, want %s in UTC", got.NextRunAt, tt.runAt)
+			}
+		})
+	}
+}
+
+func TestRescheduleTaskNotScheduled(t *testing.T) {
+	useMemoryStore(t)
+	task := &model.Task{Type: "email", Payload: model.JSON(`{}`), Status: model.StatusPending}
+	if err := store.Default.Create(context.Background(), task); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	_, err := rescheduleTask(task.ID, time.Now().Add(time.Hour))
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.From != model.StatusPending {
+		t.Errorf("rescheduleTask() error = %v, want TransitionError from pending", err)
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+const (
+	MaxBatchSize   = 5000 // 批量提交一次最多包含的任务数
+	batchFlushSize = 500  // 批量提交后每次从 outbox 发布、一起等待确认的消息数
+)
+
+// ErrBatchRejected 原子模式下其他任务校验失败，该任务没有被创建
+var ErrBatchRejected = errors.New("not created because another task in the atomic batch failed")
+
+type BatchTaskRequest struct {
+	Tasks []TaskRequest `json:"tasks" binding:"required"`
+	// 可选，为 true 时任何一个任务校验失败都不创建任何任务；原子模式不支持幂等键和唯一任务
+	Atomic bool `json:"atomic"`
+}
+
+// BatchItemResult 批量提交中单个任务的结果，Status 与单独提交该任务时的 HTTP 状态码相同
+type BatchItemResult struct {
+	Index  int         `json:"index"`
+	Status int         `json:"status"`
+	Task   *model.Task `json:"task,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type BatchTaskResponse struct {
+	Created int               `json:"created"` // 新创建的任务数
+	Failed  int               `json:"failed"`  // 失败的任务数，原子模式下包括因其他任务失败而没有创建的任务
+	Results []BatchItemResult `json:"results"` // 与请求中的 tasks 一一对应
+}
+
+// CreateTaskBatch godoc
+// @Summary Create tasks in bulk
+// @Description Submit up to 5000 tasks in one request. Plain tasks are inserted in one transaction and published together;
+// @Description tasks with idempotency_key or unique deduplication are submitted one by one. Every task gets its own result with the
+// @Description status code it would have got from POST /tasks. With atomic=true nothing is created unless every task is valid.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param batch body BatchTaskRequest true "Tasks"
+// @Success 200 {object} BatchTaskResponse "Results of a non-atomic batch, check each item"
+// @Success 201 {object} BatchTaskResponse "All tasks of an atomic batch were created"
+// @Failure 400 {object} BatchTaskResponse "An atomic batch was rejected"
+// @Failure 500 {object} BatchTaskResponse "An atomic batch could not be saved"
+// @Router /tasks/batch [post]
+func CreateTaskBatch(c *gin.Context) {
+	var req BatchTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Tasks) == 0 || len(req.Tasks) > MaxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("tasks must contain 1-%d items", MaxBatchSize)})
+		return
+	}
+
+	resp := SubmitTaskBatch(req.Tasks, req.Atomic)
+	switch {
+	case !req.Atomic:
+		c.JSON(http.StatusOK, resp)
+	case resp.Failed > 0 && resp.Results[0].Status >= http.StatusInternalServerError:
+		// 原子模式下保存失败时所有任务的结果都相同
+		c.JSON(http.StatusInternalServerError, resp)
+	case resp.Failed > 0:
+		c.JSON(http.StatusBadRequest, resp)
+	default:
+		c.JSON(http.StatusCreated, resp)
+	}
+}
+
+// SubmitTaskBatch 校验并保存一批任务，返回每个任务的结果
+//
+// 没有幂等键、也不需要去重的任务在一个事务中批量写入，然后按批发布到队列；其余任务逐个走 SubmitTask 的流程。
+// atomic 为 true 时任何一个任务校验失败或保存失败都不创建任何任务。
+func SubmitTaskBatch(reqs []TaskRequest, atomic bool) *BatchTaskResponse {
+	resp := &BatchTaskResponse{Results: make([]BatchItemResult, len(reqs))}
+	var (
+		batch   []*model.Task
+		indexes []int // batch 中每个任务在请求中的位置
+		singles []int // 需要逐个提交的任务在请求中的位置
+	)
+	for i, req := range reqs {
+		resp.Results[i].Index = i
+		task, err := newBatchTask(req, atomic)
+		if err != nil {
+			resp.fail(i, err)
+			continue
+		}
+		if task == nil {
+			singles = append(singles, i)
+			continue
+		}
+		batch = append(batch, task)
+		indexes = append(indexes, i)
+	}
+
+	if atomic && resp.Failed > 0 {
+		for _, i := range indexes {
+			resp.fail(i, ErrBatchRejected)
+		}
+		return resp
+	}
+
+	if len(batch) > 0 {
+		if err := store.Default.CreateBatch(context.Background(), batch); err != nil {
+			fmt.Printf("❌ Failed to save %d tasks: %v\n", len(batch), err)
+			for _, i := range indexes {
+				resp.fail(i, errors.New("failed to save task"))
+			}
+			batch = nil
+		} else {
+			fmt.Printf("✅ create %d tasks in DB\n", len(batch))
+		}
+	}
+
+	// 与 SubmitTask 一样缓存新创建的任务
+	var pending []uuid.UUID
+	for j, task := range batch {
+		cacheNewTask(task)
+		resp.Results[indexes[j]].Status = http.StatusCreated
+		resp.Results[indexes[j]].Task = task
+		resp.Created++
+		if task.Status == model.StatusPending {
+			pending = append(pending, task.ID)
+		}
+	}
+	// 立即发布，失败时任务留在 outbox 中由调度器补发
+	for ids := range slices.Chunk(pending, batchFlushSize) {
+		flushOutbox(ids...)
+	}
+
+	for _, i := range singles {
+		task, created, err := submitTask(reqs[i])
+		if err != nil {
+			resp.fail(i, err)
+			continue
+		}
+		resp.Results[i].Task = task
+		resp.Results[i].Status = http.StatusOK
+		if created {
+			resp.Results[i].Status = http.StatusCreated
+			resp.Created++
+		}
+	}
+	return resp
+}
+
+// newBatchTask 校验批量提交中的一个任务，返回 nil 表示该任务需要通过 submitTask 逐个提交
+func newBatchTask(req TaskRequest, atomic bool) (*model.Task, error) {
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	task, err := newTask(req)
+	if err != nil {
+		return nil, err
+	}
+	if task.IdempotencyKey == nil && task.UniqueKey == "" {
+		return task, nil
+	}
+	if atomic {
+		return nil, fmt.Errorf("%w: idempotency_key and unique tasks are not supported in atomic batches", ErrInvalidTask)
+	}
+	return nil, nil
+}
+
+// fail 记录第 i 个任务的错误
+func (r *BatchTaskResponse) fail(i int, err error) {
+	r.Results[i].Status = taskErrorStatus(err)
+	if errors.Is(err, ErrBatchRejected) {
+		r.Results[i].Status = http.StatusFailedDependency
+	}
+	r.Results[i].Error = err.Error()
+	r.Failed++
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+)
+
+// failingBatchStore 批量写入总是失败的存储，其他方法使用内存实现
+type failingBatchStore struct {
+	*store.MemoryStore
+}
+
+func (failingBatchStore) CreateBatch(ctx context.Context, tasks []*model.Task) error {
+	return errors.New("database is unavailable")
+}
+
+func batchRequest(taskType string) TaskRequest {
+	return TaskRequest{Type: taskType, Payload: model.JSON(`{"to":"a@example.com"}`)}
+}
+
+// statuses 返回每个任务结果的状态码
+func statuses(resp *BatchTaskResponse) []int {
+	codes := make([]int, len(resp.Results))
+	for i, r := range resp.Results {
+		codes[i] = r.Status
+	}
+	return codes
+}
+
+// countTasksInStore 返回当前存储中的任务数
+func countTasksInStore(t *testing.T) int64 {
+	t.Helper()
+	n, err := store.Default.Count(context.Background(), store.TaskFilter{})
+	if err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	return n
+}
+
+// drainQueue 读取队列中已发布的消息数，等待 wait 后仍没有新消息时返回
+func drainQueue(t *testing.T, queue string, wait time.Duration) int {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, err := mq.Default.Consume(ctx, queue, 100)
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	n := 0
+	for {
+		select {
+		case d := <-msgs:
+			d.Ack()
+			n++
+		case <-time.After(wait):
+			return n
+		}
+	}
+}
+
+func TestSubmitTaskBatchAtomicRollback(t *testing.T) {
+	useMemoryStore(t)
+	useMemoryBroker(t)
+	reqs := []TaskRequest{batchRequest("email"), {Payload: model.JSON(`{}`)}, batchRequest("email")}
+
+	resp := SubmitTaskBatch(reqs, true)
+	want := []int{http.StatusFailedDependency, http.StatusBadRequest, http.StatusFailedDependency}
+	if got := statuses(resp); !slices.Equal(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if resp.Created != 0 || resp.Failed != len(reqs) {
+		t.Errorf("created = %d, failed = %d, want 0 and %d", resp.Created, resp.Failed, len(reqs))
+	}
+	if n := countTasksInStore(t); n != 0 {
+		t.Errorf("atomic batch with an invalid task saved %d tasks", n)
+	}
+	if n := drainQueue(t, mq.DefaultQueue, 50*time.Millisecond); n != 0 {
+		t.Errorf("atomic batch with an invalid task published %d messages", n)
+	}
+}
+
+// TestSubmitTaskBatchResults 非原子模式下每个任务都有自己的结果，所有 pending 任务都被发布
+func TestSubmitTaskBatchResults(t *testing.T) {
+	useMemoryStore(t)
+	useMemoryBroker(t)
+	keyed := batchRequest("email")
+	keyed.IdempotencyKey = "batch-results"
+	if _, err := SubmitTask(keyed); err != nil {
+		t.Fatalf("SubmitTask() error = %v", err)
+	}
+	drainQueue(t, mq.DefaultQueue, 50*time.Millisecond)
+
+	// 超过一次发布的消息数，确认分批发布不会遗漏
+	var reqs []TaskRequest
+	for range batchFlushSize + 10 {
+		reqs = append(reqs, batchRequest("email"))
+	}
+	scheduled := batchRequest("email")
+	runAt := time.Now().Add(time.Hour)
+	scheduled.RunAt = &runAt
+	reqs = append(reqs, TaskRequest{Payload: model.JSON(`{}`)}, keyed, scheduled)
+
+	resp := SubmitTaskBatch(reqs, false)
+	plain := batchFlushSize + 10
+	for i, r := range resp.Results[:plain] {
+		if r.Status != http.StatusCreated || r.Task == nil || r.Index != i {
+			t.Fatalf("result %d = %+v, want 201 with the task", i, r)
+		}
+	}
+	want := []int{http.StatusBadRequest, http.StatusOK, http.StatusCreated}
+	if got := statuses(resp)[plain:]; !slices.Equal(got, want) {
+		t.Errorf("statuses of invalid, repeated and scheduled tasks = %v, want %v", got, want)
+	}
+	if resp.Created != plain+1 || resp.Failed != 1 {
+		t.Errorf("created = %d, failed = %d, want %d and 1", resp.Created, resp.Failed, plain+1)
+	}
+	if got := resp.Results[plain+2].Task.Status; got != model.StatusScheduled {
+		t.Errorf("task with run_at has status %s, want %s", got, model.StatusScheduled)
+	}
+	if n := drainQueue(t, mq.DefaultQueue, 100*time.Millisecond); n != plain {
+		t.Errorf("published %d messages, want %d", n, plain)
+	}
+}
+
+// TestSubmitTaskBatchSaveFailure 批量写入失败时这些任务返回 500，逐个提交的任务不受影响
+func TestSubmitTaskBatchSaveFailure(t *testing.T) {
+	store.Default = failingBatchStore{useMemoryStore(t)}
+	useMemoryBroker(t)
+	keyed := batchRequest("email")
+	keyed.IdempotencyKey = "batch-save-failure"
+	reqs := []TaskRequest{batchRequest("email"), keyed, batchRequest("email")}
+
+	resp := SubmitTaskBatch(reqs, false)
+	want := []int{http.StatusInternalServerError, http.StatusCreated, http.StatusInternalServerError}
+	if got := statuses(resp); !slices.Equal(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if resp.Created != 1 || resp.Failed != 2 {
+		t.Errorf("created = %d, failed = %d, want 1 and 2", resp.Created, resp.Failed)
+	}
+	if n := drainQueue(t, mq.DefaultQueue, 50*time.Millisecond); n != 1 {
+		t.Errorf("published %d messages, want only the task submitted on its own", n)
+	}
+
+	resp = SubmitTaskBatch([]TaskRequest{batchRequest("email"), batchRequest("email")}, true)
+	want = []int{http.StatusInternalServerError, http.StatusInternalServerError}
+	if got := statuses(resp); !slices.Equal(got, want) || resp.Created != 0 {
+		t.Errorf("atomic statuses = %v, created = %d, want %v and 0", got, resp.Created, want)
+	}
+}
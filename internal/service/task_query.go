@@ -0,0 +1,359 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// taskSorts 列表支持的 sort 参数
+var taskSorts = map[string]store.TaskOrder{
+	"priority":   store.OrderByPriority,
+	"created_at": store.OrderByCreated,
+	"updated_at": store.OrderByUpdated,
+}
+
+// pageCursor 键集分页游标的内容，记录生成游标时的排序，换了排序的游标不能继续使用
+type pageCursor struct {
+	Sort string `json:"s"`
+	Asc  bool   `json:"a,omitempty"`
+	store.TaskCursor
+}
+
+// ListTasks godoc
+// @Summary List tasks
+// @Description List tasks matching the filters. Pages are addressed by next_cursor (keyset pagination, stable while tasks are being added);
+// @Description offset is still accepted for small result sets. With format=ndjson all matching tasks are streamed one JSON object per line, ignoring limit.
+// @Description Fields inside payload and result are matched with payload.<path>=value or result.<path>=value, e.g. payload.user.id=42;
+// @Description path segments are separated by dots, numeric segments index arrays, and values are compared as text.
+// @Tags tasks
+// @Produce json
+// @Produce application/x-ndjson
+// @Param status query string false "Comma separated statuses, e.g. pending,running; unknown statuses are rejected with 400"
+// @Param type query string false "Task type"
+// @Param queue query string false "Queue name"
+// @Param priority query int false "Exact priority"
+// @Param min_priority query int false "Minimum priority"
+// @Param retry_count query int false "Exact retry count"
+// @Param min_retry_count query int false "Minimum retry count"
+// @Param tag query string false "Comma separated tags, tasks must have all of them"
+// @Param created_after query string false "Created at or after (RFC3339)"
+// @Param created_before query string false "Created before (RFC3339)"
+// @Param updated_after query string false "Updated at or after (RFC3339)"
+// @Param updated_before query string false "Updated before (RFC3339)"
+// @Param sort query string false "priority (default), created_at or updated_at; ties are broken by created_at and id"
+// @Param order query string false "desc (default) or asc"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Offset, cannot be combined with cursor"
+// @Param format query string false "json (default) or ndjson"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /tasks [get]
+func ListTasks(c *gin.Context) {
+	filter, sort, err := parseTaskFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		if c.Query("offset") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor and offset are mutually exclusive"})
+			return
+		}
+		cursor, err := decodeCursor(raw, sort, filter.Ascending)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.After = &cursor
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+	case "ndjson":
+		streamTasks(c, filter)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or ndjson"})
+		return
+	}
+
+	limit, offset, err := parsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Limit = limit + 1 // 多取一条判断是否还有下一页
+	filter.Offset = offset
+
+	tasks, err := store.Default.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tasks"})
+		return
+	}
+	resp := gin.H{}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+		resp["next_cursor"] = encodeCursor(&tasks[len(tasks)-1], sort, filter.Ascending)
+	}
+	if tasks == nil {
+		tasks = []model.Task{}
+	}
+	resp["tasks"] = tasks
+
+	// 游标翻页时不再统计总数，避免大表上每一页都做一次 COUNT
+	if filter.After == nil {
+		countFilter := filter
+		countFilter.Limit, countFilter.Offset = 0, 0
+		total, err := store.Default.Count(c.Request.Context(), countFilter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count tasks"})
+			return
+		}
+		resp["total"] = total
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// streamTasks 以 NDJSON 格式分批输出所有满足条件的任务，每批之后 flush，内存占用与结果集大小无关
+func streamTasks(c *gin.Context, filter store.TaskFilter) {
+	ctx := c.Request.Context()
+	filter.Limit = maxPageLimit
+	filter.Offset = 0
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	for {
+		tasks, err := store.Default.List(ctx, filter)
+		if err != nil {
+			// 响应头已经发出，只能中断输出，客户端通过不完整的最后一行发现错误
+			if ctx.Err() == nil {
+				fmt.Printf("❌ Failed to export tasks: %v\n", err)
+			}
+			return
+		}
+		for i := range tasks {
+			if err := enc.Encode(&tasks[i]); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+		if len(tasks) < filter.Limit {
+			return
+		}
+		cursor := store.CursorOf(&tasks[len(tasks)-1], filter.Order)
+		filter.After = &cursor
+	}
+}
+
+// parseTaskFilter 解析列表的过滤和排序参数，返回规范化后的 sort 名称
+func parseTaskFilter(c *gin.Context) (store.TaskFilter, string, error) {
+	filter := store.TaskFilter{
+		Type:  c.Query("type"),
+		Queue: c.Query("queue"),
+	}
+	if status := c.Query("status"); status != "" {
+		for _, part := range strings.Split(status, ",") {
+			s := model.TaskStatus(strings.TrimSpace(part))
+			if !s.IsValid() {
+				return filter, "", fmt.Errorf("invalid status %q, must be one of %v", part, model.TaskStatuses)
+			}
+			filter.Statuses = append(filter.Statuses, s)
+		}
+	}
+	if tag := c.Query("tag"); tag != "" {
+		filter.Tags = strings.Split(tag, ",")
+	}
+	matches, err := parseJSONMatches(c)
+	if err != nil {
+		return filter, "", err
+	}
+	filter.JSON = matches
+
+	ints := []struct {
+		name string
+		dst  **int
+	}{
+		{"priority", &filter.Priority},
+		{"min_priority", &filter.MinPriority},
+		{"retry_count", &filter.RetryCount},
+		{"min_retry_count", &filter.MinRetryCount},
+	}
+	for _, p := range ints {
+		if *p.dst, err = queryInt(c, p.name); err != nil {
+			return filter, "", err
+		}
+	}
+	times := []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_after", &filter.UpdatedAfter},
+		{"updated_before", &filter.UpdatedBefore},
+	}
+	for _, p := range times {
+		if *p.dst, err = queryTime(c, p.name); err != nil {
+			return filter, "", err
+		}
+	}
+
+	sort := c.DefaultQuery("sort", "priority")
+	order, ok := taskSorts[sort]
+	if !ok {
+		return filter, "", fmt.Errorf("sort must be one of priority, created_at, updated_at")
+	}
+	filter.Order = order
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return filter, "", fmt.Errorf("order must be asc or desc")
+	}
+	return filter, sort, nil
+}
+
+// jsonPathSegment JSON 路径中每一段允许的字符
+var jsonPathSegment = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseJSONMatches 解析 payload.<path>=value 和 result.<path>=value 形式的 JSON 路径过滤条件，路径用 . 分隔
+func parseJSONMatches(c *gin.Context) ([]store.JSONMatch, error) {
+	var matches []store.JSONMatch
+	for key, values := range c.Request.URL.Query() {
+		column, path, ok := strings.Cut(key, ".")
+		if !ok || (column != "payload" && column != "result") {
+			continue
+		}
+		segments := strings.Split(path, ".")
+		for _, seg := range segments {
+			if !jsonPathSegment.MatchString(seg) {
+				return nil, fmt.Errorf("invalid JSON path %q: segments may only contain letters, digits, _ and -", key)
+			}
+		}
+		for _, v := range values {
+			matches = append(matches, store.JSONMatch{Column: column, Path: segments, Value: v})
+		}
+	}
+	return matches, nil
+}
+
+// queryInt 解析整数查询参数，未指定时返回 nil
+func queryInt(c *gin.Context, name string) (*int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+	return &v, nil
+}
+
+// queryTime 解析 RFC3339 时间查询参数，未指定时返回 nil
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 time", name)
+	}
+	return &v, nil
+}
+
+// encodeCursor 生成从 task 之后继续翻页的游标
+func encodeCursor(task *model.Task, sort string, asc bool) string {
+	body, _ := json.Marshal(pageCursor{
+		Sort:       sort,
+		Asc:        asc,
+		TaskCursor: store.CursorOf(task, taskSorts[sort]),
+	})
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+// decodeCursor 解析游标，并确认它是在相同的排序下生成的
+func decodeCursor(raw, sort string, asc bool) (store.TaskCursor, error) {
+	var cursor pageCursor
+	body, err := base64.RawURLEncoding.DecodeString(raw)
+	if err == nil {
+		err = json.Unmarshal(body, &cursor)
+	}
+	if err != nil || cursor.ID == uuid.Nil {
+		return store.TaskCursor{}, errors.New("invalid cursor")
+	}
+	if cursor.Sort != sort || cursor.Asc != asc {
+		return store.TaskCursor{}, errors.New("cursor was created with a different sort or order")
+	}
+	return cursor.TaskCursor, nil
+}
+
+// parsePage 解析 limit/offset 分页参数
+func parsePage(c *gin.Context) (int, int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageLimit)))
+	if err != nil || limit <= 0 || limit > maxPageLimit {
+		return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+	}
+	return limit, offset, nil
+}
+
+// GetTaskHistory godoc
+// @Summary Get task history
+// @Description List the status changes of a task in order, including who made them (worker ID, api, scheduler or reaper)
+// @Tags tasks
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {array} model.TaskEvent
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tasks/{id}/history [get]
+func GetTaskHistory(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task id"})
+		return
+	}
+
+	if _, err := store.Default.Get(c.Request.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondTaskError(c, ErrTaskNotFound)
+			return
+		}
+		respondTaskError(c, err)
+		return
+	}
+	events, err := store.Default.History(c.Request.Context(), id)
+	if err != nil {
+		respondTaskError(c, fmt.Errorf("failed to load task history: %w", err))
+		return
+	}
+	if events == nil {
+		events = []model.TaskEvent{}
+	}
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	task := &model.Task{
+		ID:        uuid.New(),
+		Priority:  7,
+		CreatedAt: time.Date(2026, 3, 4, 5, 6, 7, 123456789, time.UTC),
+		UpdatedAt: time.Date(2026, 3, 5, 0, 0, 0, 1, time.UTC),
+	}
+	tests := []struct {
+		sort     string
+		asc      bool
+		priority int
+		at       time.Time
+	}{
+		{"priority", false, 7, task.CreatedAt},
+		{"priority", true, 7, task.CreatedAt},
+		{"created_at", false, 0, task.CreatedAt},
+		{"updated_at", true, 0, task.UpdatedAt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			cursor, err := decodeCursor(encodeCursor(task, tt.sort, tt.asc), tt.sort, tt.asc)
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if cursor.ID != task.ID || cursor.Priority != tt.priority || !cursor.At.Equal(tt.at) {
+				t.Errorf("decodeCursor() = %+v, want id=%s priority=%d at=%s", cursor, task.ID, tt.priority, tt.at)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	task := &model.Task{ID: uuid.New(), CreatedAt: time.Now()}
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name string
+		raw  string
+		sort string
+		asc  bool
+	}{
+		{"not base64", "!!!", "priority", false},
+		{"not json", encode("cursor"), "priority", false},
+		{"missing id", encode(`{"s":"priority","t":"2026-01-01T00:00:00Z"}`), "priority", false},
+		{"different sort", encodeCursor(task, "created_at", false), "priority", false},
+		{"different order", encodeCursor(task, "priority", true), "priority", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.raw, tt.sort, tt.asc); err == nil {
+				t.Errorf("decodeCursor(%q) succeeded, want error", tt.raw)
+			}
+		})
+	}
+}
+
+func TestListTasksJSONFilter(t *testing.T) {
+	payloads := []string{
+		`{"user":{"id":42,"vip":true},"items":[{"sku":"x-1"}]}`,
+		`{"user":{"id":42,"vip":false},"items":[{"sku":"y-2"}]}`,
+		`{"user":{"id":7},"items":[]}`,
+		`"legacy string payload"`,
+	}
+	for _, p := range payloads {
+		if _, err := SubmitTask(TaskRequest{Type: "json_filter_test", Payload: model.JSON(p)}); err != nil {
+			t.Fatalf("SubmitTask(%s) error = %v", p, err)
+		}
+	}
+
+	// pending 任务提交后立即发布到队列
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, err := mq.Default.Consume(ctx, mq.DefaultQueue, len(payloads))
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	for range payloads {
+		select {
+		case d := <-msgs:
+			d.Ack()
+		case <-time.After(time.Second):
+			t.Fatal("submitted task was not published")
+		}
+	}
+
+	r := gin.New()
+	r.GET("/tasks", ListTasks)
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"payload.user.id=42", 2},
+		{"payload.user.id=7", 1},
+		{"payload.user.vip=true", 1},
+		{"payload.user.vip=false", 1},
+		{"payload.items.0.sku=y-2", 1},
+		{"payload.user.id=42&payload.user.vip=true", 1},
+		{"payload.user.missing=42", 0},
+		{"payload.user.id=", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks?type=json_filter_test&"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Tasks []model.Task `json:"tasks"`
+				Total int          `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Tasks) != tt.want || resp.Total != tt.want {
+				t.Errorf("got %d tasks (total %d), want %d", len(resp.Tasks), resp.Total, tt.want)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks?payload.user..id=42", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("empty path segment: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestListTasksStatusFilter(t *testing.T) {
+	useMemoryStore(t)
+	useMemoryBroker(t)
+	if _, err := SubmitTask(TaskRequest{Type: "email", Payload: model.JSON(`{}`)}); err != nil {
+		t.Fatalf("SubmitTask() error = %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/tasks", ListTasks)
+	tests := []struct {
+		query string
+		code  int
+		total int
+	}{
+		{"status=pending", http.StatusOK, 1},
+		{"status=running,pending", http.StatusOK, 1},
+		{"status=failed", http.StatusOK, 0},
+		{"status=pendng", http.StatusBadRequest, 0},
+		{"status=pending,", http.StatusBadRequest, 0},
+		{"status=PENDING", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks?"+tt.query, nil))
+			if w.Code != tt.code {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.code, w.Body)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Total int `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Total != tt.total {
+				t.Errorf("total = %d, want %d", resp.Total, tt.total)
+			}
+		})
+	}
+}
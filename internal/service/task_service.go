@@ -1,33 +1,141 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/WangZhaoye/go-task-processor/internal/cache"
-	"github.com/WangZhaoye/go-task-processor/internal/db"
+	"github.com/WangZhaoye/go-task-processor/internal/config"
 	"github.com/WangZhaoye/go-task-processor/internal/model"
 	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// TaskTypeChecker 用于判断任务类型是否有对应的处理器（通常是 TaskTypeCatalog）
+type TaskTypeChecker interface {
+	Has(taskType string) bool
+}
+
+var taskTypes TaskTypeChecker
+
+// TaskQueueRouter 可选接口，返回任务类型注册时指定的命名队列
+type TaskQueueRouter interface {
+	Queue(taskType string) string
+}
+
+// SetTaskTypeChecker 设置任务类型校验器，未设置时不校验任务类型
+func SetTaskTypeChecker(checker TaskTypeChecker) {
+	taskTypes = checker
+}
+
 type TaskRequest struct {
-	Type    string `json:"type" binding:"required"`
-	Payload string `json:"payload" binding:"required"`
+	Type        string             `json:"type" binding:"required"`
+	Payload     model.JSON         `json:"payload" binding:"required" swaggertype:"object"` // 任意 JSON；字符串按原样保存为 JSON 字符串
+	Priority    int                `json:"priority"`                                        // 可选，0-9，数值越大越先执行
+	RetryPolicy *model.RetryPolicy `json:"retry_policy"`                                    // 可选，覆盖任务类型的重试策略
+	RunAt       *time.Time         `json:"run_at"`                                          // 可选，在指定时间执行
+	Delay       *model.Duration    `json:"delay" swaggertype:"string"`                      // 可选，延迟指定时间后执行，例如 "10m"
+	Timeout     *model.Duration    `json:"timeout" swaggertype:"string"`                    // 可选，单次执行超时，覆盖任务类型的默认值
+	ExpiresAt   *time.Time         `json:"expires_at"`                                      // 可选，超过该时间仍未开始执行则丢弃
+	Tags        []string           `json:"tags"`                                            // 可选，任务标签，用于查询时过滤
+
+	// 可选，幂等键，也可以通过 Idempotency-Key 请求头指定；IDEMPOTENCY_WINDOW 内重复提交返回原任务
+	IdempotencyKey string `json:"idempotency_key"`
+
+	// 可选，唯一任务的去重键，默认由 payload 计算；去重时间窗口内相同键的任务未结束时返回该任务
+	UniqueKey string          `json:"unique_key"`
+	UniqueTTL *model.Duration `json:"unique_ttl" swaggertype:"string"` // 可选，去重时间窗口，覆盖任务类型的设置
+}
+
+// IdempotencyKeyHeader 指定幂等键的请求头
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// MaxIdempotencyKeyLength 幂等键的最大长度
+const MaxIdempotencyKeyLength = 255
+
+const (
+	MaxTaskTags      = 20 // 单个任务的最大标签数
+	MaxTaskTagLength = 64 // 单个标签的最大长度
+)
+
+var (
+	// ErrInvalidTask 任务请求参数不合法
+	ErrInvalidTask = errors.New("invalid task request")
+	// ErrTaskNotFound 任务不存在
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTaskState 任务当前状态不允许该操作
+	ErrTaskState = errors.New("operation not allowed in current task state")
+	// ErrIdempotencyConflict 幂等键已被内容不同的请求使用
+	ErrIdempotencyConflict = errors.New("idempotency key reused with a different request")
+)
+
+// TransitionError 条件更新失败：状态机不允许该转换，或任务已被其他请求修改
+//
+// errors.Is(err, ErrTaskState) 对 TransitionError 成立。
+type TransitionError struct {
+	TaskID  uuid.UUID
+	From    model.TaskStatus // 任务的当前状态
+	To      model.TaskStatus // 目标状态，只更新字段时为空
+	Version int64            // 任务的当前版本
+
+	Expected        []model.TaskStatus // 调用方期望的状态，为空表示不限
+	ExpectedVersion *int64             // 调用方期望的版本，nil 表示不限
+}
+
+func (e *TransitionError) Error() string {
+	switch {
+	case e.ExpectedVersion != nil && *e.ExpectedVersion != e.Version:
+		return fmt.Sprintf("task %s was modified concurrently: version is %d, expected %d", e.TaskID, e.Version, *e.ExpectedVersion)
+	case e.To != "" && !e.From.CanTransitionTo(e.To):
+		return fmt.Sprintf("task %s cannot transition from %s to %s", e.TaskID, e.From, e.To)
+	default:
+		return fmt.Sprintf("task %s is %s, expected %v", e.TaskID, e.From, e.Expected)
+	}
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrTaskState
+}
+
+// newTransitionError 根据条件更新的参数和任务的当前值构造 TransitionError
+func newTransitionError(current *model.Task, u store.TaskUpdate) *TransitionError {
+	e := &TransitionError{
+		TaskID:          current.ID,
+		From:            current.Status,
+		Version:         current.Version,
+		Expected:        u.From,
+		ExpectedVersion: u.Version,
+	}
+	if u.Status != nil {
+		e.To = *u.Status
+	}
+	return e
 }
 
 // CreateTask godoc
 // @Summary Create a new task
-// @Description Submit a task to be processed asynchronously
+// @Description Submit a task to be processed asynchronously, or at run_at / after delay.
+// @Description Repeating a request with the same Idempotency-Key returns the original task with 200.
 // @Tags tasks
 // @Accept json
 // @Produce json
-// @Param task body model.Task true "Task"
-// @Success 200 {object} map[string]string
+// @Param Idempotency-Key header string false "Idempotency key"
+// @Param task body TaskRequest true "Task"
+// @Success 200 {object} model.Task
+// @Success 201 {object} model.Task
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /tasks [post]
 func CreateTask(c *gin.Context) {
 	var req TaskRequest
@@ -36,45 +144,271 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 
-	// 总是生成新的UUID
-	id := uuid.New()
-	
-	task := model.Task{
-		ID:        id,
-		Type:      req.Type,
-		Payload:   req.Payload,
-		Status:    model.StatusPending,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	if key := c.GetHeader(IdempotencyKeyHeader); key != "" {
+		if req.IdempotencyKey != "" && req.IdempotencyKey != key {
+			respondTaskError(c, fmt.Errorf("%w: idempotency_key does not match the %s header", ErrInvalidTask, IdempotencyKeyHeader))
+			return
+		}
+		req.IdempotencyKey = key
 	}
 
-	//create in DB
-	if err := db.DB.Create(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save task"})
+	task, created, err := submitTask(req)
+	if err != nil {
+		respondTaskError(c, err)
 		return
 	}
-	fmt.Println("✅ create task in DB ")
 
-	// 缓存新创建的任务
+	if !created {
+		c.JSON(http.StatusOK, task)
+		return
+	}
+	c.JSON(http.StatusCreated, task)
+}
+
+// SubmitTask 校验并保存任务；立即执行的任务发布到队列，定时任务等待调度器发布
+//
+// 设置了幂等键且窗口期内已提交过相同的请求时，返回原来的任务。
+func SubmitTask(req TaskRequest) (*model.Task, error) {
+	task, _, err := submitTask(req)
+	return task, err
+}
+
+// submitTask 与 SubmitTask 相同，created 为 false 表示返回的是幂等键对应的已有任务
+func submitTask(req TaskRequest) (task *model.Task, created bool, err error) {
+	return submitTaskWith(req, func(ctx context.Context, task *model.Task) (*model.Task, error) {
+		return store.Default.CreateIdempotent(ctx, task, time.Now().Add(-config.Cfg.IdempotencyWindow))
+	})
+}
+
+// cacheNewTask 缓存新创建的任务及其状态，缓存失败不影响主流程
+func cacheNewTask(task *model.Task) {
 	if err := cache.CacheTask(task.ID.String(), task); err != nil {
 		fmt.Printf("⚠️ Failed to cache task: %v\n", err)
-		// 缓存失败不影响主流程
 	}
-
-	// 缓存任务状态
 	if err := cache.CacheTaskStatus(task.ID.String(), string(task.Status)); err != nil {
 		fmt.Printf("⚠️ Failed to cache task status: %v\n", err)
 	}
+}
 
-	//push to MQ
-	taskJson, _ := json.Marshal(task)
-	if err := mq.PublishTask(string(taskJson)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue task"})
-		return
+// saveTaskFunc 把新任务写入数据库；幂等键已被使用时返回 store.ErrDuplicate 和已有的任务
+type saveTaskFunc func(ctx context.Context, task *model.Task) (*model.Task, error)
+
+// submitTaskWith 与 submitTask 相同，但由 save 写入任务，例如周期任务在抢占触发的同一事务中写入
+func submitTaskWith(req TaskRequest, save saveTaskFunc) (task *model.Task, created bool, err error) {
+	task, err = newTask(req)
+	if err != nil {
+		return nil, false, err
 	}
-	fmt.Println("✅ create task in MQ")
 
-	c.JSON(http.StatusCreated, task)
+	// 唯一任务先占用去重键，已有相同键的任务未结束时直接返回该任务
+	if task.UniqueKey != "" {
+		existing, err := claimUnique(task, uniqueTTL(req))
+		if err != nil {
+			return nil, false, err
+		}
+		if existing != nil {
+			fmt.Printf("♻️ Unique key %s matched task %s\n", task.UniqueKey, existing.ID)
+			return existing, false, nil
+		}
+	}
+
+	// pending 任务和 outbox 消息在同一个事务中写入，定时任务到期后由调度器写入 outbox
+	existing, err := save(context.Background(), task)
+	if err != nil && task.UniqueKey != "" {
+		releaseUnique(task)
+	}
+	if errors.Is(err, store.ErrDuplicate) {
+		if existing.IdempotencyHash != task.IdempotencyHash {
+			return nil, false, fmt.Errorf("%w: %s", ErrIdempotencyConflict, req.IdempotencyKey)
+		}
+		fmt.Printf("♻️ Idempotency key %s matched task %s\n", req.IdempotencyKey, existing.ID)
+		return existing, false, nil
+	}
+	if errors.Is(err, store.ErrConflict) {
+		// 周期任务的本次触发已被其他实例抢占
+		return nil, false, err
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to save task: %v\n", err)
+		return nil, false, errors.New("failed to save task")
+	}
+	fmt.Println("✅ create task in DB ")
+	cacheNewTask(task)
+
+	if task.Status == model.StatusScheduled {
+		fmt.Printf("⏰ task scheduled at %s\n", task.NextRunAt.Format(time.RFC3339))
+		return task, true, nil
+	}
+
+	// 立即发布，失败时任务留在 outbox 中由调度器补发
+	flushOutbox(task.ID)
+	return task, true, nil
+}
+
+// newTask 校验请求并构造待保存的任务
+func newTask(req TaskRequest) (*model.Task, error) {
+	// 拒绝没有处理器的任务类型，避免任务进入队列后才失败
+	if taskTypes != nil && !taskTypes.Has(req.Type) {
+		return nil, fmt.Errorf("%w: unknown task type: %s (no worker has registered it)", ErrInvalidTask, req.Type)
+	}
+
+	if req.RetryPolicy != nil {
+		if err := req.RetryPolicy.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: invalid retry_policy: %v", ErrInvalidTask, err)
+		}
+	}
+
+	runAt, err := resolveRunAt(req.RunAt, req.Delay)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Priority < 0 || req.Priority > mq.MaxPriority {
+		return nil, fmt.Errorf("%w: priority must be between 0 and %d", ErrInvalidTask, mq.MaxPriority)
+	}
+	if req.Timeout != nil && *req.Timeout < 0 {
+		return nil, fmt.Errorf("%w: timeout must not be negative", ErrInvalidTask)
+	}
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidTask)
+		}
+		if err := checkExpiresAt(req.ExpiresAt, runAt); err != nil {
+			return nil, err
+		}
+		expiresAt := req.ExpiresAt.UTC()
+		req.ExpiresAt = &expiresAt
+	}
+
+	if req.Payload.IsNull() {
+		return nil, fmt.Errorf("%w: payload is required", ErrInvalidTask)
+	}
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, req.Payload); err != nil {
+		return nil, fmt.Errorf("%w: invalid payload: %v", ErrInvalidTask, err)
+	}
+	req.Payload = model.JSON(payload.Bytes())
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := resolveQueue(req.Type)
+	if !slices.Contains(config.Cfg.TaskQueues, queue) {
+		return nil, fmt.Errorf("%w: task type %s is routed to unknown queue %s", ErrInvalidTask, req.Type, queue)
+	}
+
+	// 总是生成新的UUID
+	id := uuid.New()
+	now := time.Now()
+
+	task := model.Task{
+		ID:          id,
+		Type:        req.Type,
+		Payload:     req.Payload,
+		Status:      model.StatusPending,
+		Priority:    req.Priority,
+		Queue:       queue,
+		RetryPolicy: req.RetryPolicy,
+		ExpiresAt:   req.ExpiresAt,
+		Tags:        tags,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if req.Timeout != nil {
+		task.Timeout = *req.Timeout
+	}
+	if runAt != nil && runAt.After(now) {
+		task.Status = model.StatusScheduled
+		task.NextRunAt = runAt
+	}
+
+	if req.IdempotencyKey != "" {
+		if len(req.IdempotencyKey) > MaxIdempotencyKeyLength {
+			return nil, fmt.Errorf("%w: idempotency key must be at most %d characters", ErrInvalidTask, MaxIdempotencyKeyLength)
+		}
+		key := req.IdempotencyKey
+		task.IdempotencyKey = &key
+		task.IdempotencyHash = requestHash(req)
+	}
+
+	if req.UniqueTTL != nil && *req.UniqueTTL <= 0 {
+		return nil, fmt.Errorf("%w: unique_ttl must be positive", ErrInvalidTask)
+	}
+	if uniqueTTL(req) > 0 {
+		task.UniqueKey = req.UniqueKey
+		if task.UniqueKey == "" {
+			task.UniqueKey = contentKey(task.Payload)
+		}
+	}
+	return &task, nil
+}
+
+// normalizeTags 校验标签并去掉重复的标签，保持原有顺序
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	for _, tag := range tags {
+		if tag == "" || len(tag) > MaxTaskTagLength || strings.Contains(tag, ",") {
+			return nil, fmt.Errorf("%w: tags must be 1-%d characters without commas", ErrInvalidTask, MaxTaskTagLength)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > MaxTaskTags {
+		return nil, fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidTask, MaxTaskTags)
+	}
+	return normalized, nil
+}
+
+// requestHash 计算提交请求（不含幂等键）的摘要
+func requestHash(req TaskRequest) string {
+	req.IdempotencyKey = ""
+	body, _ := json.Marshal(req)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// resolveQueue 按 TASK_ROUTES 配置、注册时的 WithQueue、default 的顺序选择队列
+func resolveQueue(taskType string) string {
+	if queue, ok := config.Cfg.TaskRoutes[taskType]; ok {
+		return queue
+	}
+	if router, ok := taskTypes.(TaskQueueRouter); ok {
+		if queue := router.Queue(taskType); queue != "" {
+			return queue
+		}
+	}
+	return mq.DefaultQueue
+}
+
+// checkExpiresAt 任务必须在过期之前执行，expiresAt 或 runAt 为 nil 时不检查
+func checkExpiresAt(expiresAt, runAt *time.Time) error {
+	if expiresAt != nil && runAt != nil && !expiresAt.After(*runAt) {
+		return fmt.Errorf("%w: expires_at must be after the run time", ErrInvalidTask)
+	}
+	return nil
+}
+
+// resolveRunAt 根据 run_at 或 delay 计算执行时间，两者都未指定时返回 nil
+func resolveRunAt(runAt *time.Time, delay *model.Duration) (*time.Time, error) {
+	if runAt != nil && delay != nil {
+		return nil, fmt.Errorf("%w: run_at and delay are mutually exclusive", ErrInvalidTask)
+	}
+	if delay != nil {
+		if *delay < 0 {
+			return nil, fmt.Errorf("%w: delay must not be negative", ErrInvalidTask)
+		}
+		t := time.Now().Add(time.Duration(*delay)).UTC()
+		return &t, nil
+	}
+	if runAt == nil {
+		return nil, nil
+	}
+	// 客户端可能带任意时区偏移，统一保存为 UTC
+	t := runAt.UTC()
+	return &t, nil
 }
 
 func GetTask(c *gin.Context) {
@@ -99,10 +433,12 @@ func GetTask(c *gin.Context) {
 	}
 
 	// 缓存未命中，从数据库查询
-	if err := db.DB.First(&task, "id = ?", uuidVal).Error; err != nil {
+	stored, err := store.Default.Get(c.Request.Context(), uuidVal)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 		return
 	}
+	task = *stored
 	fmt.Println("✅ get task from DB")
 
 	// 将查询结果缓存起来
@@ -113,39 +449,78 @@ func GetTask(c *gin.Context) {
 	c.JSON(http.StatusOK, task)
 }
 
+// syncTaskCache 条件更新任务状态后同步缓存
+func syncTaskCache(id uuid.UUID, status model.TaskStatus) {
+	if err := cache.CacheTaskStatus(id.String(), string(status)); err != nil {
+		fmt.Printf("⚠️ Failed to update cached task status: %v\n", err)
+	}
+	if err := cache.InvalidateTaskCache(id.String()); err != nil {
+		fmt.Printf("⚠️ Failed to invalidate task cache: %v\n", err)
+	}
+}
+
 // TaskUpdateOptions 定义任务更新选项
 type TaskUpdateOptions struct {
 	Status     *model.TaskStatus `json:"status,omitempty"`
-	Result     *string           `json:"result,omitempty"`
+	Result     *model.JSON       `json:"result,omitempty"`
+	Summary    *string           `json:"summary,omitempty"`
 	RetryCount *int              `json:"retry_count,omitempty"`
+	LastError  *string           `json:"last_error,omitempty"`
+	NextRunAt  *time.Time        `json:"next_run_at,omitempty"`
+	ErrorKind  *model.ErrorKind  `json:"error_kind,omitempty"`
+
+	// WorkerID 和 LeaseExpiresAt 设置 running 任务的租约
+	WorkerID       *string    `json:"-"`
+	LeaseExpiresAt *time.Time `json:"-"`
+
+	// ExpectStatus 非空时只有任务处于其中某个状态才会更新，否则返回 ErrTaskState
+	ExpectStatus []model.TaskStatus `json:"-"`
+	// ExpectVersion 非 nil 时只有任务的版本等于该值才会更新，否则返回 ErrTaskState
+	ExpectVersion *int64 `json:"-"`
+	// Enqueue 为 true 时在同一事务中写入 outbox 消息，并在提交后立即发布
+	Enqueue bool `json:"-"`
+
+	// Actor 和 Reason 记录在任务历史中，Actor 为空时使用任务的 Worker ID
+	Actor  string `json:"-"`
+	Reason string `json:"-"`
 }
 
 // UpdateTask 通用的任务更新方法，支持选择性更新字段
+//
+// 状态变更必须符合状态机；不符合状态机、不满足 ExpectStatus/ExpectVersion 时返回 *TransitionError，
+// 任务不存在时返回 ErrTaskState 和 ErrTaskNotFound。
 func UpdateTask(id uuid.UUID, options TaskUpdateOptions) error {
-	updateFields := map[string]interface{}{
-		"updated_at": time.Now(),
-	}
-
-	// 根据选项添加需要更新的字段
-	if options.Status != nil {
-		updateFields["status"] = *options.Status
-	}
-	if options.Result != nil {
-		updateFields["result"] = *options.Result
-	}
-	if options.RetryCount != nil {
-		updateFields["retry_count"] = *options.RetryCount
-	}
+	_, err := updateTask(id, options)
+	return err
+}
 
-	// 执行数据库更新
-	err := db.DB.
-		Model(&model.Task{}).
-		Where("id = ?", id).
-		Updates(updateFields).
-		Error
+// updateTask 与 UpdateTask 相同，成功时返回更新后的任务
+func updateTask(id uuid.UUID, options TaskUpdateOptions) (*model.Task, error) {
+	u := store.TaskUpdate{
+		Status:     options.Status,
+		Result:     options.Result,
+		Summary:    options.Summary,
+		RetryCount: options.RetryCount,
+		LastError:  options.LastError,
+		ErrorKind:  options.ErrorKind,
+		NextRunAt:  options.NextRunAt,
+		From:       options.ExpectStatus,
+		Version:    options.ExpectVersion,
+		Enqueue:    options.Enqueue,
 
-	if err != nil {
-		return err
+		WorkerID:       options.WorkerID,
+		LeaseExpiresAt: options.LeaseExpiresAt,
+		Actor:          options.Actor,
+		Reason:         options.Reason,
+	}
+	task, err := store.Default.Update(context.Background(), id, u)
+	switch {
+	case errors.Is(err, store.ErrConflict):
+		return nil, newTransitionError(task, u)
+	case errors.Is(err, store.ErrNotFound):
+		return nil, fmt.Errorf("%w: %w", ErrTaskState, ErrTaskNotFound)
+	case err != nil:
+		return nil, err
 	}
 
 	// 更新缓存中的任务状态（如果状态有变化）
@@ -160,7 +535,10 @@ func UpdateTask(id uuid.UUID, options TaskUpdateOptions) error {
 		fmt.Printf("⚠️ Failed to invalidate task cache: %v\n", cacheErr)
 	}
 
-	return nil
+	if options.Enqueue {
+		flushOutbox(id)
+	}
+	return task, nil
 }
 
 // UpdateTaskStatus 更新任务状态（保持向后兼容）
@@ -170,11 +548,11 @@ func UpdateTaskStatus(id uuid.UUID, status model.TaskStatus) error {
 	})
 }
 
-// FinishTask 完成任务（保持向后兼容）
+// FinishTask 完成任务，result 作为人可读的 summary 保存（保持向后兼容）
 func FinishTask(id uuid.UUID, result string, status model.TaskStatus) error {
 	return UpdateTask(id, TaskUpdateOptions{
-		Status: &status,
-		Result: &result,
+		Status:  &status,
+		Summary: &result,
 	})
 }
 
@@ -184,3 +562,90 @@ func UpdateTaskRetryCount(id uuid.UUID, retryCount int) error {
 		RetryCount: &retryCount,
 	})
 }
+
+// StartTask Worker 开始执行版本为 version 的任务消息，记录租约并返回任务进入 running 后的版本
+//
+// 消息是任务入队时的快照，只有任务仍是该版本的 pending 时才能开始执行。
+// 任务已被取消、已结束、已重新入队或正由其他 Worker 执行时，这条消息是重复或过期的投递，返回 *TransitionError。
+// Worker 崩溃后任务停在 running，租约过期后由 ReapExpiredLeases 回收，不依赖消息的重新投递。
+func StartTask(id uuid.UUID, version int64, workerID string, leaseUntil time.Time) (int64, error) {
+	status := model.StatusRunning
+	task, err := updateTask(id, TaskUpdateOptions{
+		Status:         &status,
+		WorkerID:       &workerID,
+		LeaseExpiresAt: &leaseUntil,
+		ExpectStatus:   []model.TaskStatus{model.StatusPending},
+		ExpectVersion:  &version,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return task.Version, nil
+}
+
+// HeartbeatTasks 延长 workerID 正在执行的任务的租约，返回仍由该 Worker 持有的任务
+func HeartbeatTasks(workerID string, ids []uuid.UUID, leaseUntil time.Time) ([]uuid.UUID, error) {
+	return store.Default.Heartbeat(context.Background(), workerID, ids, leaseUntil)
+}
+
+// ExpireTask 丢弃超过 expires_at 仍未开始执行的任务，version 为读到任务时的版本
+func ExpireTask(id uuid.UUID, version int64) error {
+	status := model.StatusExpired
+	summary := "Task expired before it could run"
+	return UpdateTask(id, TaskUpdateOptions{
+		Status:        &status,
+		Summary:       &summary,
+		ExpectStatus:  []model.TaskStatus{model.StatusScheduled, model.StatusPending, model.StatusRetrying},
+		ExpectVersion: &version,
+	})
+}
+
+// CompleteTask 记录执行成功及其结构化结果和摘要，version 为 StartTask 返回的版本；任务执行期间被取消时返回 ErrTaskState
+func CompleteTask(id uuid.UUID, version int64, result model.JSON, summary string) error {
+	status := model.StatusSuccess
+	return UpdateTask(id, TaskUpdateOptions{
+		Status:        &status,
+		Result:        &result,
+		Summary:       &summary,
+		ExpectStatus:  []model.TaskStatus{model.StatusRunning},
+		ExpectVersion: &version,
+	})
+}
+
+// ReleaseTask 把被中断的任务恢复为 pending 并重新入队，原来的消息随之过期
+func ReleaseTask(id uuid.UUID, version int64) error {
+	status := model.StatusPending
+	return UpdateTask(id, TaskUpdateOptions{
+		Status:        &status,
+		ExpectStatus:  []model.TaskStatus{model.StatusRunning},
+		ExpectVersion: &version,
+		Enqueue:       true,
+	})
+}
+
+// ScheduleRetry 记录失败原因，并安排任务在 runAt 之后重新入队
+func ScheduleRetry(id uuid.UUID, version int64, retryCount int, lastError string, kind model.ErrorKind, runAt time.Time) error {
+	status := model.StatusRetrying
+	return UpdateTask(id, TaskUpdateOptions{
+		Status:        &status,
+		RetryCount:    &retryCount,
+		LastError:     &lastError,
+		ErrorKind:     &kind,
+		NextRunAt:     &runAt,
+		ExpectStatus:  []model.TaskStatus{model.StatusRunning},
+		ExpectVersion: &version,
+	})
+}
+
+// MarkTaskDead 将任务标记为死信状态，并记录摘要、最后一次错误及其分类
+func MarkTaskDead(id uuid.UUID, version int64, summary string, lastError string, kind model.ErrorKind) error {
+	status := model.StatusDead
+	return UpdateTask(id, TaskUpdateOptions{
+		Status:        &status,
+		Summary:       &summary,
+		LastError:     &lastError,
+		ErrorKind:     &kind,
+		ExpectStatus:  []model.TaskStatus{model.StatusPending, model.StatusRunning},
+		ExpectVersion: &version,
+	})
+}
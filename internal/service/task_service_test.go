@@ -0,0 +1,80 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/config"
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// postTask 通过 POST /tasks 提交任务，返回状态码和响应中的任务
+func postTask(t *testing.T, body string, idempotencyKey string) (int, model.Task) {
+	t.Helper()
+	r := gin.New()
+	r.POST("/tasks", CreateTask)
+	req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var task model.Task
+	if w.Code < http.StatusBadRequest {
+		if err := json.Unmarshal(w.Body.Bytes(), &task); err != nil {
+			t.Fatalf("decode response %s: %v", w.Body, err)
+		}
+	}
+	return w.Code, task
+}
+
+func TestCreateTaskIdempotency(t *testing.T) {
+	useMemoryStore(t)
+	useMemoryBroker(t)
+	const body = `{"type":"email","payload":{"to":"a@example.com"}}`
+
+	code, first := postTask(t, body, "order-1")
+	if code != http.StatusCreated {
+		t.Fatalf("first request: status = %d, want %d", code, http.StatusCreated)
+	}
+
+	t.Run("repeat returns the original task", func(t *testing.T) {
+		code, task := postTask(t, body, "order-1")
+		if code != http.StatusOK || task.ID != first.ID {
+			t.Errorf("status = %d, task = %s, want %d and %s", code, task.ID, http.StatusOK, first.ID)
+		}
+	})
+
+	t.Run("different payload conflicts", func(t *testing.T) {
+		code, _ := postTask(t, `{"type":"email","payload":{"to":"b@example.com"}}`, "order-1")
+		if code != http.StatusConflict {
+			t.Errorf("status = %d, want %d", code, http.StatusConflict)
+		}
+	})
+
+	t.Run("header and body disagree", func(t *testing.T) {
+		code, _ := postTask(t, `{"type":"email","payload":{},"idempotency_key":"order-2"}`, "order-1")
+		if code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("repeat after the window creates a new task", func(t *testing.T) {
+		window := config.Cfg.IdempotencyWindow
+		config.Cfg.IdempotencyWindow = time.Millisecond
+		t.Cleanup(func() { config.Cfg.IdempotencyWindow = window })
+		time.Sleep(5 * time.Millisecond)
+
+		code, task := postTask(t, body, "order-1")
+		if code != http.StatusCreated || task.ID == first.ID {
+			t.Errorf("status = %d, task = %s, want %d and a new task", code, task.ID, http.StatusCreated)
+		}
+	})
+}
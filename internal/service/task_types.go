@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+)
+
+// TaskTypeCacheTTL 已注册任务类型的缓存时间，Worker 修改注册设置后其他进程最多经过该时间生效
+const TaskTypeCacheTTL = 30 * time.Second
+
+// taskTypeMissInterval 遇到未注册类型时重新加载的最小间隔，避免未知类型的请求每次都查询数据库
+const taskTypeMissInterval = time.Second
+
+// RegisterTaskTypes 把 Worker 注册的任务类型写入数据库，workerID 记录最后一次注册的 Worker
+//
+// 同一类型被其他 Worker 以不同设置注册过时以本次为准，并打印警告：同一类型的所有 Worker 应使用相同的设置。
+func RegisterTaskTypes(workerID string, types []model.TaskType) error {
+	ctx := context.Background()
+	existing, err := store.Default.TaskTypes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load task types: %w", err)
+	}
+	registered := make(map[string]model.TaskType, len(existing))
+	for _, t := range existing {
+		registered[t.Name] = t
+	}
+	for i := range types {
+		types[i].WorkerID = workerID
+		if old, ok := registered[types[i].Name]; ok && old.WorkerID != workerID && !sameTaskTypeSettings(old, types[i]) {
+			log.Printf("⚠️ Task type %s was registered by worker %s with different settings, overriding\n", old.Name, old.WorkerID)
+		}
+	}
+	if err := store.Default.SaveTaskTypes(ctx, types); err != nil {
+		return fmt.Errorf("failed to save task types: %w", err)
+	}
+	if c, ok := taskTypes.(*TaskTypeCatalog); ok {
+		c.invalidate()
+	}
+	return nil
+}
+
+// sameTaskTypeSettings 比较两次注册的设置，忽略注册的 Worker 和时间
+func sameTaskTypeSettings(a, b model.TaskType) bool {
+	a.WorkerID, b.WorkerID = "", ""
+	a.UpdatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	return reflect.DeepEqual(a, b)
+}
+
+// TaskTypeCatalog 从数据库读取 Worker 注册的任务类型，实现 TaskTypeChecker 及其可选接口
+//
+// API 和调度器不需要编译 Worker 的处理器，提交任务时的类型校验、队列、去重时间窗口和重试策略
+// 都以 Worker 注册的设置为准。还没有任何 Worker 注册过的类型使用创建时传入的默认设置，
+// 也没有默认设置的类型会被拒绝。
+type TaskTypeCatalog struct {
+	mu       sync.Mutex
+	defaults []model.TaskType
+	types    map[string]model.TaskType
+	loadedAt time.Time
+}
+
+// NewTaskTypeCatalog 创建任务类型目录，首次查询时从数据库加载
+//
+// defaults 是 Worker 注册之前就可以提交的类型，通常是内置类型，数据库中同名类型的设置优先。
+func NewTaskTypeCatalog(defaults ...model.TaskType) *TaskTypeCatalog {
+	c := &TaskTypeCatalog{defaults: defaults}
+	// 第一次加载失败时也能使用默认设置
+	c.types = c.merge(nil)
+	return c
+}
+
+// merge 返回默认设置与数据库中注册的设置合并后的结果，registered 优先
+func (c *TaskTypeCatalog) merge(registered []model.TaskType) map[string]model.TaskType {
+	types := make(map[string]model.TaskType, len(c.defaults)+len(registered))
+	for _, t := range c.defaults {
+		types[t.Name] = t
+	}
+	for _, t := range registered {
+		types[t.Name] = t
+	}
+	return types
+}
+
+// lookup 返回任务类型的注册设置，缓存过期或类型未知时从数据库重新加载
+func (c *TaskTypeCatalog) lookup(taskType string) (model.TaskType, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.types[taskType]
+	age := time.Since(c.loadedAt)
+	if age < TaskTypeCacheTTL && (ok || age < taskTypeMissInterval) {
+		return t, ok
+	}
+
+	types, err := store.Default.TaskTypes(context.Background())
+	if err != nil {
+		// 加载失败时继续使用旧的缓存，下次查询再重试
+		log.Printf("❌ Failed to load task types: %v", err)
+		return t, ok
+	}
+	c.types = c.merge(types)
+	c.loadedAt = time.Now()
+	t, ok = c.types[taskType]
+	return t, ok
+}
+
+// invalidate 丢弃缓存，下次查询时重新加载
+func (c *TaskTypeCatalog) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadedAt = time.Time{}
+}
+
+// Has 判断是否有 Worker 注册过该任务类型，或者该类型有默认设置
+func (c *TaskTypeCatalog) Has(taskType string) bool {
+	_, ok := c.lookup(taskType)
+	return ok
+}
+
+// Queue 返回注册时为任务类型设置的命名队列，空表示未设置
+func (c *TaskTypeCatalog) Queue(taskType string) string {
+	t, _ := c.lookup(taskType)
+	return t.Queue
+}
+
+// UniqueTTL 返回注册时为任务类型设置的去重时间窗口，0 表示不是唯一任务
+func (c *TaskTypeCatalog) UniqueTTL(taskType string) time.Duration {
+	t, _ := c.lookup(taskType)
+	return time.Duration(t.UniqueTTL)
+}
+
+// RetryPolicy 返回任务类型的重试策略
+func (c *TaskTypeCatalog) RetryPolicy(taskType string) model.RetryPolicy {
+	t, _ := c.lookup(taskType)
+	return model.DefaultRetryPolicy.Merge(t.RetryPolicy)
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+)
+
+// TestTaskTypeCatalogDefaults 没有 Worker 注册时使用默认设置，注册后以数据库中的设置为准
+func TestTaskTypeCatalogDefaults(t *testing.T) {
+	useMemoryStore(t)
+	catalog := NewTaskTypeCatalog(model.TaskType{Name: "email", Queue: "critical"})
+	useTaskTypes(t, catalog)
+
+	if !catalog.Has("email") || catalog.Queue("email") != "critical" {
+		t.Errorf("before registration: Has(email) = %v, Queue(email) = %q, want true and critical", catalog.Has("email"), catalog.Queue("email"))
+	}
+	if catalog.Has("report") {
+		t.Error("Has(report) = true before any worker registered it")
+	}
+
+	err := RegisterTaskTypes("worker-1", []model.TaskType{
+		{Name: "email", Queue: "bulk"},
+		{Name: "report", UniqueTTL: model.Duration(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("RegisterTaskTypes() error = %v", err)
+	}
+	if got := catalog.Queue("email"); got != "bulk" {
+		t.Errorf("after registration: Queue(email) = %q, want the registered queue bulk", got)
+	}
+	if !catalog.Has("report") || catalog.UniqueTTL("report") != time.Minute {
+		t.Errorf("after registration: Has(report) = %v, UniqueTTL(report) = %v", catalog.Has("report"), catalog.UniqueTTL("report"))
+	}
+}
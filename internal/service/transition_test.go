@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+)
+
+// newStoredTask 在 store.Default 中创建一个处于 status 的任务
+func newStoredTask(t *testing.T, status model.TaskStatus) *model.Task {
+	t.Helper()
+	task := &model.Task{Type: "email", Payload: model.JSON(`{}`), Status: status}
+	if status == model.StatusScheduled {
+		runAt := time.Now().Add(time.Hour)
+		task.NextRunAt = &runAt
+	}
+	if err := store.Default.Create(context.Background(), task); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return task
+}
+
+func TestTransitionTask(t *testing.T) {
+	testStores(t, func(t *testing.T) {
+		t.Run("stale version", func(t *testing.T) {
+			task := newStoredTask(t, model.StatusPending)
+			stale := task.Version
+			if _, err := StartTask(task.ID, stale, "worker-1", time.Now().Add(time.Minute)); err != nil {
+				t.Fatalf("StartTask() error = %v", err)
+			}
+
+			// 同一条消息的重复投递带着旧版本
+			_, err := StartTask(task.ID, stale, "worker-2", time.Now().Add(time.Minute))
+			var te *TransitionError
+			if !errors.As(err, &te) || !errors.Is(err, ErrTaskState) {
+				t.Fatalf("StartTask() with a stale version error = %v, want *TransitionError", err)
+			}
+			if te.ExpectedVersion == nil || *te.ExpectedVersion != stale || te.Version != stale+1 || te.From != model.StatusRunning {
+				t.Errorf("TransitionError = %+v, want version %d expected %d from running", te, stale+1, stale)
+			}
+		})
+
+		t.Run("illegal transition", func(t *testing.T) {
+			task := newStoredTask(t, model.StatusPending)
+			version, err := StartTask(task.ID, task.Version, "worker-1", time.Now().Add(time.Minute))
+			if err != nil {
+				t.Fatalf("StartTask() error = %v", err)
+			}
+			if err := CompleteTask(task.ID, version, nil, "done"); err != nil {
+				t.Fatalf("CompleteTask() error = %v", err)
+			}
+
+			status := model.StatusPending
+			err = UpdateTask(task.ID, TaskUpdateOptions{Status: &status})
+			var te *TransitionError
+			if !errors.As(err, &te) {
+				t.Fatalf("success → pending error = %v, want *TransitionError", err)
+			}
+			if te.TaskID != task.ID || te.From != model.StatusSuccess || te.To != model.StatusPending {
+				t.Errorf("TransitionError = %+v, want %s success → pending", te, task.ID)
+			}
+			if got, _ := store.Default.Get(context.Background(), task.ID); got.Status != model.StatusSuccess || got.Version != version+1 {
+				t.Errorf("task after rejected transition = %s v%d, want success v%d", got.Status, got.Version, version+1)
+			}
+		})
+
+		t.Run("unexpected status", func(t *testing.T) {
+			task := newStoredTask(t, model.StatusScheduled)
+			_, err := rescheduleTask(task.ID, time.Now().Add(2*time.Hour))
+			if err != nil {
+				t.Fatalf("rescheduleTask() error = %v", err)
+			}
+			if _, err := cancelTask(task.ID); err != nil {
+				t.Fatalf("cancelTask() error = %v", err)
+			}
+			_, err = rescheduleTask(task.ID, time.Now().Add(3*time.Hour))
+			var te *TransitionError
+			if !errors.As(err, &te) || te.From != model.StatusCancelled {
+				t.Errorf("rescheduleTask() of a cancelled task error = %v, want *TransitionError from cancelled", err)
+			}
+		})
+	})
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/store"
+	"github.com/google/uuid"
+)
+
+// DefaultUniqueTTL 只指定了 unique_key 的任务使用的去重时间窗口
+const DefaultUniqueTTL = time.Hour
+
+// ErrUniqueConflict 相同去重键的任务正在提交，还不能确定它是否会创建成功
+var ErrUniqueConflict = errors.New("a task with the same unique key is being submitted")
+
+// TaskUniqueness 可选接口，返回任务类型注册时设置的去重时间窗口，0 表示不去重
+type TaskUniqueness interface {
+	UniqueTTL(taskType string) time.Duration
+}
+
+// uniqueTTL 按 unique_ttl、注册时的 WithUnique、DefaultUniqueTTL 的顺序确定去重时间窗口，0 表示不去重
+func uniqueTTL(req TaskRequest) time.Duration {
+	if req.UniqueTTL != nil {
+		return time.Duration(*req.UniqueTTL)
+	}
+	if u, ok := taskTypes.(TaskUniqueness); ok {
+		if ttl := u.UniqueTTL(req.Type); ttl > 0 {
+			return ttl
+		}
+	}
+	if req.UniqueKey != "" {
+		return DefaultUniqueTTL
+	}
+	return 0
+}
+
+// contentKey 由压缩空白后的 payload 计算默认的去重键
+func contentKey(payload model.JSON) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// uniqueLockKey 返回任务入队时占用的 Redis key，去重键只在同一任务类型内生效
+func uniqueLockKey(task *model.Task) string {
+	return cache.UniqueKeyPrefix + task.Type + ":" + task.UniqueKey
+}
+
+// claimUnique 为新任务占用去重键，返回仍在占用该键的未结束任务
+//
+// 占用者已经结束时新任务接管该键；占用者还没有写入数据库时返回 ErrUniqueConflict。
+func claimUnique(task *model.Task, ttl time.Duration) (*model.Task, error) {
+	key := uniqueLockKey(task)
+	token := task.ID.String()
+	for range 3 {
+		ok, err := cache.AcquireLock(key, token, ttl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim unique key: %w", err)
+		}
+		if ok {
+			return nil, nil
+		}
+
+		holder, held, err := cache.LockHolder(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read unique key: %w", err)
+		}
+		if !held {
+			// 刚好过期，重新占用
+			continue
+		}
+		holderID, err := uuid.Parse(holder)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unique key holder %q", holder)
+		}
+		existing, err := store.Default.Get(context.Background(), holderID)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrUniqueConflict, task.UniqueKey)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load task holding unique key: %w", err)
+		}
+		if !existing.Status.IsFinished() {
+			return existing, nil
+		}
+
+		// 占用者已经结束，由新任务接管；失败说明有其他请求先接管了，重新检查
+		ok, err = cache.ReplaceLock(key, holder, token, ttl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim unique key: %w", err)
+		}
+		if ok {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUniqueConflict, task.UniqueKey)
+}
+
+// releaseUnique 任务没有创建成功时归还去重键
+func releaseUnique(task *model.Task) {
+	if err := cache.ReleaseLock(uniqueLockKey(task), task.ID.String()); err != nil {
+		fmt.Printf("⚠️ Failed to release unique key %s: %v\n", task.UniqueKey, err)
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/google/uuid"
+)
+
+func uniqueRequest(key string) TaskRequest {
+	return TaskRequest{Type: "report", Payload: model.JSON(`{"day":"2026-01-01"}`), UniqueKey: key}
+}
+
+func TestSubmitUniqueTask(t *testing.T) {
+	useMemoryStore(t)
+	useMemoryBroker(t)
+	req := uniqueRequest("report-" + uuid.NewString())
+
+	first, created, err := submitTask(req)
+	if err != nil || !created {
+		t.Fatalf("first submitTask() = %v, %v, want a new task", created, err)
+	}
+
+	t.Run("claim returns the existing task", func(t *testing.T) {
+		task, created, err := submitTask(req)
+		if err != nil || created || task.ID != first.ID {
+			t.Errorf("submitTask() = %v, %v, %v, want the existing task %s", task, created, err, first.ID)
+		}
+	})
+
+	t.Run("takeover after the holder finishes", func(t *testing.T) {
+		version, err := StartTask(first.ID, first.Version, "worker-1", time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("StartTask() error = %v", err)
+		}
+		if err := CompleteTask(first.ID, version, nil, "done"); err != nil {
+			t.Fatalf("CompleteTask() error = %v", err)
+		}
+
+		task, created, err := submitTask(req)
+		if err != nil || !created || task.ID == first.ID {
+			t.Fatalf("submitTask() = %v, %v, want a new task", created, err)
+		}
+		holder, held, err := cache.LockHolder(uniqueLockKey(task))
+		if err != nil || !held || holder != task.ID.String() {
+			t.Errorf("unique key held by %q (%v, %v), want the new task %s", holder, held, err, task.ID)
+		}
+	})
+}
+
+// TestSubmitUniqueTaskBeingSaved 占用者还没有写入数据库时返回 ErrUniqueConflict，而不是重复创建
+func TestSubmitUniqueTaskBeingSaved(t *testing.T) {
+	useMemoryStore(t)
+	req := uniqueRequest("report-" + uuid.NewString())
+	task := &model.Task{Type: req.Type, UniqueKey: req.UniqueKey}
+	if ok, err := cache.AcquireLock(uniqueLockKey(task), uuid.NewString(), time.Minute); err != nil || !ok {
+		t.Fatalf("AcquireLock() = %v, %v", ok, err)
+	}
+
+	if _, _, err := submitTask(req); !errors.Is(err, ErrUniqueConflict) {
+		t.Errorf("submitTask() error = %v, want ErrUniqueConflict", err)
+	}
+}
+
+// TestSubmitUniqueTaskSaveFailure 任务没有写入数据库时归还去重键，下一次提交可以创建
+func TestSubmitUniqueTaskSaveFailure(t *testing.T) {
+	useMemoryStore(t)
+	useMemoryBroker(t)
+	req := uniqueRequest("report-" + uuid.NewString())
+
+	_, _, err := submitTaskWith(req, func(ctx context.Context, task *model.Task) (*model.Task, error) {
+		return nil, errors.New("database is unavailable")
+	})
+	if err == nil {
+		t.Fatal("submitTaskWith() succeeded although the save failed")
+	}
+	if _, created, err := submitTask(req); err != nil || !created {
+		t.Errorf("submitTask() after a failed save = %v, %v, want a new task", created, err)
+	}
+}
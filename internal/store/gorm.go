@@ -0,0 +1,678 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gormStore 基于 GORM 的 TaskStore，支持 PostgreSQL 和 SQLite
+type gormStore struct {
+	db *gorm.DB
+
+	// relayMu 在 SQLite 上串行执行 RelayOutbox：SQLite 没有 SKIP LOCKED，读事务不会互相阻塞，
+	// 提交后立即发布和后台中继同时读到同一条消息时会重复发布。SQLite 只支持单进程使用。
+	relayMu sync.Mutex
+}
+
+// NewGormStore 使用已迁移的数据库连接创建 TaskStore，写入和查询条件中的时间都会先转换为 UTC
+func NewGormStore(db *gorm.DB) TaskStore {
+	return &gormStore{db: withUTC(db)}
+}
+
+// skipLocked 给查询加上 FOR UPDATE SKIP LOCKED；SQLite 没有行锁，调用方需要用条件更新或 relayMu 避免重复处理
+func (s *gormStore) skipLocked(tx *gorm.DB) *gorm.DB {
+	if s.db.Dialector.Name() == "postgres" {
+		return tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+	}
+	return tx
+}
+
+// forUpdate 给查询加上 FOR UPDATE，锁住读到的行直到事务结束
+func (s *gormStore) forUpdate(tx *gorm.DB) *gorm.DB {
+	if s.db.Dialector.Name() == "postgres" {
+		return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	return tx
+}
+
+// writeEvent 在事务 tx 中写入状态变更历史，e 为 nil 时不写入
+func writeEvent(tx *gorm.DB, e *model.TaskEvent) error {
+	if e == nil {
+		return nil
+	}
+	if err := tx.Create(e).Error; err != nil {
+		return fmt.Errorf("failed to write task event: %w", err)
+	}
+	return nil
+}
+
+func (s *gormStore) Create(ctx context.Context, task *model.Task) error {
+	if task.ID == uuid.Nil {
+		task.ID = uuid.New()
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createTask(tx, task)
+	})
+}
+
+func (s *gormStore) CreateBatch(ctx context.Context, tasks []*model.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	for _, task := range tasks {
+		if task.ID == uuid.Nil {
+			task.ID = uuid.New()
+		}
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createTasks(tx, tasks)
+	})
+}
+
+// insertBatchSize 批量插入时每条 INSERT 语句的行数，避免超过数据库的参数个数上限
+const insertBatchSize = 500
+
+// createTask 在事务 tx 中写入新任务、标签和创建事件，pending 任务同时写入 outbox
+func createTask(tx *gorm.DB, task *model.Task) error {
+	return createTasks(tx, []*model.Task{task})
+}
+
+// createTasks 与 createTask 相同，每张表按 insertBatchSize 批量写入
+func createTasks(tx *gorm.DB, tasks []*model.Task) error {
+	var (
+		tags   []model.TaskTag
+		events []*model.TaskEvent
+		outbox []*model.OutboxMessage
+	)
+	for _, task := range tasks {
+		for _, tag := range task.Tags {
+			tags = append(tags, model.TaskTag{TaskID: task.ID, Tag: tag})
+		}
+		events = append(events, newEvent(nil, task, TaskUpdate{Actor: model.ActorAPI}))
+		if task.Status == model.StatusPending {
+			msg, err := newOutboxMessage(task)
+			if err != nil {
+				return err
+			}
+			outbox = append(outbox, msg)
+		}
+	}
+
+	if err := tx.CreateInBatches(tasks, insertBatchSize).Error; err != nil {
+		return err
+	}
+	if len(tags) > 0 {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(tags, insertBatchSize).Error; err != nil {
+			return fmt.Errorf("failed to write task tags: %w", err)
+		}
+	}
+	if err := tx.CreateInBatches(events, insertBatchSize).Error; err != nil {
+		return fmt.Errorf("failed to write task events: %w", err)
+	}
+	if len(outbox) > 0 {
+		if err := tx.CreateInBatches(outbox, insertBatchSize).Error; err != nil {
+			return fmt.Errorf("failed to write outbox messages: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *gormStore) CreateIdempotent(ctx context.Context, task *model.Task, since time.Time) (*model.Task, error) {
+	if task.IdempotencyKey == nil {
+		return nil, s.Create(ctx, task)
+	}
+	if task.ID == uuid.Nil {
+		task.ID = uuid.New()
+	}
+	var existing *model.Task
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		existing, err = getTaskByKey(tx, *task.IdempotencyKey)
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			return err
+		case !existing.CreatedAt.Before(since):
+			return ErrDuplicate
+		default:
+			// 幂等键已过期，旧任务让出该键
+			err := tx.Model(&model.Task{}).Where("id = ?", existing.ID).Update("idempotency_key", nil).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return createTask(tx, task)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 并发提交时另一个请求先写入了该键
+		existing, err = getTaskByKey(s.db.WithContext(ctx), *task.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		return existing, ErrDuplicate
+	}
+	if errors.Is(err, ErrDuplicate) {
+		return existing, err
+	}
+	return nil, err
+}
+
+func getTaskByKey(tx *gorm.DB, key string) (*model.Task, error) {
+	var task model.Task
+	if err := tx.First(&task, "idempotency_key = ?", key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+// writeOutbox 在事务 tx 中写入任务的 outbox 消息
+func writeOutbox(tx *gorm.DB, task *model.Task) error {
+	msg, err := newOutboxMessage(task)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(msg).Error; err != nil {
+		return fmt.Errorf("failed to write outbox message: %w", err)
+	}
+	return nil
+}
+
+// newOutboxMessage 以任务当前内容的 JSON 快照作为消息体
+func newOutboxMessage(task *model.Task) (*model.OutboxMessage, error) {
+	body, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	return &model.OutboxMessage{
+		TaskID:    task.ID,
+		Queue:     task.Queue,
+		Priority:  task.Priority,
+		Body:      string(body),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (s *gormStore) Get(ctx context.Context, id uuid.UUID) (*model.Task, error) {
+	return getTask(s.db.WithContext(ctx), id)
+}
+
+func getTask(tx *gorm.DB, id uuid.UUID) (*model.Task, error) {
+	var task model.Task
+	if err := tx.First(&task, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (s *gormStore) Update(ctx context.Context, id uuid.UUID, u TaskUpdate) (*model.Task, error) {
+	var task *model.Task
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := getTask(s.forUpdate(tx), id)
+		if err != nil {
+			return err
+		}
+		if !canUpdate(current, u) {
+			task = current
+			return ErrConflict
+		}
+
+		updated := *current
+		applyUpdate(&updated, u)
+		// 行已加锁，版本条件只是防御；Select("*") 让零值字段（如清空的租约）也被写入
+		res := tx.Model(&model.Task{}).Where("id = ? AND version = ?", id, current.Version).Select("*").Updates(&updated)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			task = current
+			return ErrConflict
+		}
+		task = &updated
+
+		if err := writeEvent(tx, newEvent(current, task, u)); err != nil {
+			return err
+		}
+		if u.Enqueue {
+			return writeOutbox(tx, task)
+		}
+		return nil
+	})
+	return task, err
+}
+
+// filter 把 TaskFilter 的条件应用到查询上
+func filter(query *gorm.DB, f TaskFilter) *gorm.DB {
+	if len(f.IDs) > 0 {
+		query = query.Where("id IN ?", f.IDs)
+	}
+	if len(f.Statuses) > 0 {
+		query = query.Where("status IN ?", f.Statuses)
+	}
+	if f.Type != "" {
+		query = query.Where("type = ?", f.Type)
+	}
+	if f.Queue != "" {
+		query = query.Where("queue = ?", f.Queue)
+	}
+	if f.Priority != nil {
+		query = query.Where("priority = ?", *f.Priority)
+	}
+	if f.MinPriority != nil {
+		query = query.Where("priority >= ?", *f.MinPriority)
+	}
+	if f.RetryCount != nil {
+		query = query.Where("retry_count = ?", *f.RetryCount)
+	}
+	if f.MinRetryCount != nil {
+		query = query.Where("retry_count >= ?", *f.MinRetryCount)
+	}
+	for _, tag := range f.Tags {
+		query = query.Where("id IN (?)", query.Session(&gorm.Session{NewDB: true}).
+			Model(&model.TaskTag{}).Select("task_id").Where("tag = ?", tag))
+	}
+	if f.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *f.CreatedBefore)
+	}
+	if f.UpdatedAfter != nil {
+		query = query.Where("updated_at >= ?", *f.UpdatedAfter)
+	}
+	if f.UpdatedBefore != nil {
+		query = query.Where("updated_at < ?", *f.UpdatedBefore)
+	}
+	if f.DueBefore != nil {
+		query = query.Where("next_run_at <= ?", *f.DueBefore)
+	}
+	if f.ExpiresBefore != nil {
+		query = query.Where("expires_at <= ?", *f.ExpiresBefore)
+	}
+	if f.LeaseBefore != nil {
+		query = query.Where("lease_expires_at < ?", *f.LeaseBefore)
+	}
+	for _, m := range f.JSON {
+		query = whereJSON(query, m)
+	}
+	return query
+}
+
+// whereJSON 按 JSON 路径过滤，PostgreSQL 使用 jsonb_extract_path_text，SQLite 使用 json_extract
+func whereJSON(query *gorm.DB, m JSONMatch) *gorm.DB {
+	if query.Dialector.Name() == "postgres" {
+		args := make([]interface{}, 0, len(m.Path)+1)
+		for _, key := range m.Path {
+			args = append(args, key)
+		}
+		args = append(args, m.Value)
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(m.Path)), ", ")
+		return query.Where("jsonb_extract_path_text("+m.Column+", "+marks+") = ?", args...)
+	}
+
+	path := "$"
+	for _, key := range m.Path {
+		if _, err := strconv.Atoi(key); err == nil {
+			path += "[" + key + "]"
+		} else {
+			path += `."` + key + `"`
+		}
+	}
+	// json_extract 把 true/false 返回为 1/0，这里转换为与 PostgreSQL 相同的文本
+	return query.Where("(CASE json_type("+m.Column+", ?) WHEN 'true' THEN 'true' WHEN 'false' THEN 'false' "+
+		"ELSE CAST(json_extract("+m.Column+", ?) AS TEXT) END) = ?", path, path, m.Value)
+}
+
+// orderKeys 返回排序方式对应的列，最后一列总是 id，保证键集分页的顺序是确定的
+func orderKeys(order TaskOrder) []string {
+	switch order {
+	case OrderByUpdated:
+		return []string{"updated_at", "id"}
+	case OrderByCreated:
+		return []string{"created_at", "id"}
+	default:
+		return []string{"priority", "created_at", "id"}
+	}
+}
+
+func (s *gormStore) List(ctx context.Context, f TaskFilter) ([]model.Task, error) {
+	query := filter(s.db.WithContext(ctx).Model(&model.Task{}), f)
+	if f.Order == OrderByNextRunAt {
+		query = query.Order("next_run_at")
+	} else {
+		dir, cmp := " DESC", "<"
+		if f.Ascending {
+			dir, cmp = " ASC", ">"
+		}
+		keys := orderKeys(f.Order)
+		for _, key := range keys {
+			query = query.Order(key + dir)
+		}
+		if f.After != nil {
+			// 行值比较 (a, b, id) < (?, ?, ?) 可以直接使用排序列上的索引
+			args := []interface{}{f.After.At, f.After.ID}
+			if f.Order == OrderByPriority {
+				args = append([]interface{}{f.After.Priority}, args...)
+			}
+			cols := strings.Join(keys, ", ")
+			marks := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+			query = query.Where("("+cols+") "+cmp+" ("+marks+")", args...)
+		}
+	}
+	if f.Limit > 0 {
+		query = query.Limit(f.Limit)
+	}
+	if f.Offset > 0 {
+		query = query.Offset(f.Offset)
+	}
+
+	var tasks []model.Task
+	if err := query.Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *gormStore) Count(ctx context.Context, f TaskFilter) (int64, error) {
+	var total int64
+	err := filter(s.db.WithContext(ctx).Model(&model.Task{}), f).Count(&total).Error
+	return total, err
+}
+
+func (s *gormStore) Lease(ctx context.Context, now time.Time, limit int) ([]model.Task, error) {
+	var leased []model.Task
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var due []model.Task
+		err := s.skipLocked(tx).
+			Where("status IN ? AND next_run_at <= ?", []model.TaskStatus{model.StatusScheduled, model.StatusRetrying}, now).
+			Order("next_run_at").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil {
+			return err
+		}
+
+		for _, task := range due {
+			before := task
+			// 没有行锁的数据库依靠状态条件避免重复抢占
+			res := tx.Model(&model.Task{}).
+				Where("id = ? AND status = ?", task.ID, task.Status).
+				Updates(map[string]interface{}{
+					"status":      model.StatusPending,
+					"next_run_at": nil,
+					"updated_at":  now,
+					"version":     gorm.Expr("version + 1"),
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			task.Status = model.StatusPending
+			task.NextRunAt = nil
+			task.UpdatedAt = now
+			task.Version++
+			if err := writeOutbox(tx, &task); err != nil {
+				return err
+			}
+			if err := writeEvent(tx, newEvent(&before, &task, TaskUpdate{Actor: model.ActorScheduler})); err != nil {
+				return err
+			}
+			leased = append(leased, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return leased, nil
+}
+
+func (s *gormStore) RelayOutbox(ctx context.Context, limit int, taskIDs []uuid.UUID, publish PublishFunc) (int, error) {
+	if s.db.Dialector.Name() != "postgres" {
+		s.relayMu.Lock()
+		defer s.relayMu.Unlock()
+	}
+
+	sent := 0
+	var publishErr error
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := s.skipLocked(tx).Where("sent_at IS NULL")
+		if len(taskIDs) > 0 {
+			query = query.Where("task_id IN ?", taskIDs)
+		}
+
+		var msgs []model.OutboxMessage
+		if err := query.Order("id").Limit(limit).Find(&msgs).Error; err != nil {
+			return fmt.Errorf("failed to query outbox: %w", err)
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+
+		var ids []uint64
+		for i, err := range publish(msgs) {
+			if err == nil {
+				ids = append(ids, msgs[i].ID)
+				continue
+			}
+			if publishErr == nil {
+				publishErr = err
+			}
+			err = tx.Model(&msgs[i]).Updates(map[string]interface{}{
+				"attempts":   gorm.Expr("attempts + 1"),
+				"last_error": err.Error(),
+			}).Error
+			if err != nil {
+				return fmt.Errorf("failed to record outbox error for message %d: %w", msgs[i].ID, err)
+			}
+		}
+
+		sent = len(ids)
+		if len(ids) > 0 {
+			err := tx.Model(&model.OutboxMessage{}).Where("id IN ?", ids).Update("sent_at", time.Now()).Error
+			if err != nil {
+				return fmt.Errorf("failed to mark %d outbox messages sent: %w", len(ids), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if sent > 0 {
+			// 事务回滚后这些消息会被再次发布
+			fmt.Printf("⚠️ %d published outbox messages will be published again\n", sent)
+		}
+		return 0, err
+	}
+	return sent, publishErr
+}
+
+func (s *gormStore) PurgeOutbox(ctx context.Context, before time.Time) error {
+	return s.db.WithContext(ctx).Where("sent_at < ?", before).Delete(&model.OutboxMessage{}).Error
+}
+
+func (s *gormStore) RepublishPending(ctx context.Context) (int, error) {
+	n := 0
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		unsent := tx.Model(&model.OutboxMessage{}).Select("task_id").Where("sent_at IS NULL")
+		var tasks []model.Task
+		err := tx.Where("status = ? AND id NOT IN (?)", model.StatusPending, unsent).
+			Order("created_at").
+			Find(&tasks).Error
+		if err != nil {
+			return fmt.Errorf("failed to query pending tasks: %w", err)
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+
+		outbox := make([]*model.OutboxMessage, 0, len(tasks))
+		for i := range tasks {
+			msg, err := newOutboxMessage(&tasks[i])
+			if err != nil {
+				return err
+			}
+			outbox = append(outbox, msg)
+		}
+		if err := tx.CreateInBatches(outbox, insertBatchSize).Error; err != nil {
+			return fmt.Errorf("failed to write outbox messages: %w", err)
+		}
+		n = len(outbox)
+		return nil
+	})
+	return n, err
+}
+
+func (s *gormStore) Heartbeat(ctx context.Context, workerID string, ids []uuid.UUID, until time.Time) ([]uuid.UUID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var owned []uuid.UUID
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		leased := func() *gorm.DB {
+			return tx.Model(&model.Task{}).
+				Where("id IN ? AND worker_id = ? AND status = ?", ids, workerID, model.StatusRunning)
+		}
+		err := leased().Updates(map[string]interface{}{"lease_expires_at": until, "heartbeat_at": time.Now()}).Error
+		if err != nil {
+			return err
+		}
+		return leased().Pluck("id", &owned).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return owned, nil
+}
+
+func (s *gormStore) History(ctx context.Context, taskID uuid.UUID) ([]model.TaskEvent, error) {
+	var events []model.TaskEvent
+	err := s.db.WithContext(ctx).Where("task_id = ?", taskID).Order("id").Find(&events).Error
+	return events, err
+}
+
+func (s *gormStore) SaveTaskTypes(ctx context.Context, types []model.TaskType) error {
+	if len(types) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&types).Error
+}
+
+func (s *gormStore) TaskTypes(ctx context.Context) ([]model.TaskType, error) {
+	var types []model.TaskType
+	err := s.db.WithContext(ctx).Order("name").Find(&types).Error
+	return types, err
+}
+
+func (s *gormStore) CreateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	err := s.db.WithContext(ctx).Create(schedule).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrNameTaken
+	}
+	return err
+}
+
+func (s *gormStore) UpdateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	schedule.UpdatedAt = time.Now()
+	res := s.db.WithContext(ctx).Model(&model.Schedule{}).
+		Where("id = ?", schedule.ID).
+		Select("*").Omit("id", "created_at").
+		Updates(schedule)
+	if errors.Is(res.Error, gorm.ErrDuplicatedKey) {
+		return ErrNameTaken
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *gormStore) GetSchedule(ctx context.Context, id uuid.UUID) (*model.Schedule, error) {
+	var schedule model.Schedule
+	if err := s.db.WithContext(ctx).First(&schedule, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (s *gormStore) ListSchedules(ctx context.Context) ([]model.Schedule, error) {
+	var schedules []model.Schedule
+	err := s.db.WithContext(ctx).Order("name").Find(&schedules).Error
+	return schedules, err
+}
+
+func (s *gormStore) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	res := s.db.WithContext(ctx).Delete(&model.Schedule{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *gormStore) DueSchedules(ctx context.Context, now time.Time) ([]model.Schedule, error) {
+	var due []model.Schedule
+	err := s.db.WithContext(ctx).
+		Where("enabled = ? AND next_run_at <= ?", true, now).
+		Order("next_run_at").
+		Find(&due).Error
+	return due, err
+}
+
+func (s *gormStore) FireSchedule(ctx context.Context, run ScheduleRun) error {
+	updates := map[string]interface{}{
+		"next_run_at": run.NextRunAt,
+		"last_run_at": run.ScheduledTime,
+		"updated_at":  time.Now(),
+	}
+	if run.Task != nil {
+		if run.Task.ID == uuid.Nil {
+			run.Task.ID = uuid.New()
+		}
+		updates["last_task_id"] = run.Task.ID
+	} else if run.LastTaskID != nil {
+		updates["last_task_id"] = *run.LastTaskID
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 条件更新会锁住该行，其他实例的抢占要等本事务结束，届时 next_run_at 已经改变
+		res := tx.Model(&model.Schedule{}).
+			Where("id = ? AND next_run_at = ?", run.ScheduleID, run.ScheduledTime).
+			Updates(updates)
+		if res.Error != nil {
+			return fmt.Errorf("failed to claim schedule: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return ErrConflict
+		}
+		if run.Task == nil {
+			return nil
+		}
+		return createTask(tx, run.Task)
+	})
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+)
+
+const jsonTestPayload = `{
+	"user": {"id": 42, "name": "张三", "tags": ["a", "b"], "vip": true, "banned": false, "note": null},
+	"amount": 1.5,
+	"count": 0,
+	"items": [{"sku": "x-1"}, {"sku": "y-2"}],
+	"quote": "say \"hi\"",
+	"empty": ""
+}`
+
+var jsonPathTests = []struct {
+	name  string
+	path  []string
+	value string
+	found bool // 路径存在且不是 null
+}{
+	{"string", []string{"user", "name"}, "张三", true},
+	{"integer", []string{"user", "id"}, "42", true},
+	{"float", []string{"amount"}, "1.5", true},
+	{"zero", []string{"count"}, "0", true},
+	{"true", []string{"user", "vip"}, "true", true},
+	{"false", []string{"user", "banned"}, "false", true},
+	{"escaped string", []string{"quote"}, `say "hi"`, true},
+	{"empty string", []string{"empty"}, "", true},
+	{"array index", []string{"user", "tags", "1"}, "b", true},
+	{"object in array", []string{"items", "0", "sku"}, "x-1", true},
+	{"null", []string{"user", "note"}, "", false},
+	{"missing key", []string{"user", "email"}, "", false},
+	{"index out of range", []string{"user", "tags", "5"}, "", false},
+	{"key on scalar", []string{"amount", "value"}, "", false},
+	{"index on object", []string{"user", "0"}, "", false},
+}
+
+func TestJSONPathText(t *testing.T) {
+	payload := model.JSON(jsonTestPayload)
+	for _, tt := range jsonPathTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := jsonPathText(payload, tt.path)
+			if found != tt.found || got != tt.value {
+				t.Errorf("jsonPathText(%v) = %q, %v, want %q, %v", tt.path, got, found, tt.value, tt.found)
+			}
+		})
+	}
+}
+
+// TestJSONFilterParity 内存实现与 SQLite 上的 SQL 过滤条件对同样的路径和值给出相同的结果
+func TestJSONFilterParity(t *testing.T) {
+	ctx := context.Background()
+	stores := testStores(t)
+	for name, s := range stores {
+		task := &model.Task{Type: "email", Payload: model.JSON(jsonTestPayload), Status: model.StatusScheduled}
+		if err := s.Create(ctx, task); err != nil {
+			t.Fatalf("%s: Create() error = %v", name, err)
+		}
+	}
+
+	for _, tt := range jsonPathTests {
+		// 存在的路径用正确的值匹配，同时确认错误的值不匹配
+		values := map[string]bool{tt.value: tt.found, tt.value + "x": false}
+		if !tt.found {
+			values = map[string]bool{"": false, "null": false}
+		}
+		for value, want := range values {
+			t.Run(tt.name+"="+value, func(t *testing.T) {
+				f := TaskFilter{JSON: []JSONMatch{{Column: "payload", Path: tt.path, Value: value}}}
+				for name, s := range stores {
+					n, err := s.Count(ctx, f)
+					if err != nil {
+						t.Fatalf("%s: Count() error = %v", name, err)
+					}
+					if got := n == 1; got != want {
+						t.Errorf("%s: match %v = %q is %v, want %v", name, tt.path, value, got, want)
+					}
+				}
+			})
+		}
+	}
+}
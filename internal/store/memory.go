@@ -0,0 +1,620 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/google/uuid"
+)
+
+// MemoryStore 进程内的 TaskStore，用于单元测试，进程退出后数据丢失
+//
+// 所有操作在同一把锁内完成，相当于每个操作都是一个串行事务。
+type MemoryStore struct {
+	mu        sync.Mutex
+	tasks     map[uuid.UUID]*model.Task
+	outbox    []model.OutboxMessage
+	outboxSeq uint64
+	events    []model.TaskEvent
+	taskTypes map[string]model.TaskType
+	schedules map[uuid.UUID]*model.Schedule
+}
+
+// NewMemoryStore 创建一个空的 MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tasks: make(map[uuid.UUID]*model.Task)}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, task *model.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if task.IdempotencyKey != nil && s.byKey(*task.IdempotencyKey) != nil {
+		return ErrDuplicate
+	}
+	return s.create(task)
+}
+
+func (s *MemoryStore) CreateIdempotent(ctx context.Context, task *model.Task, since time.Time) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if task.IdempotencyKey != nil {
+		if existing := s.byKey(*task.IdempotencyKey); existing != nil {
+			if !existing.CreatedAt.Before(since) {
+				copied := *existing
+				return &copied, ErrDuplicate
+			}
+			existing.IdempotencyKey = nil
+		}
+	}
+	return nil, s.create(task)
+}
+
+func (s *MemoryStore) CreateBatch(ctx context.Context, tasks []*model.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make(map[string]bool)
+	for _, task := range tasks {
+		if task.IdempotencyKey == nil {
+			continue
+		}
+		if keys[*task.IdempotencyKey] || s.byKey(*task.IdempotencyKey) != nil {
+			return ErrDuplicate
+		}
+		keys[*task.IdempotencyKey] = true
+	}
+	for _, task := range tasks {
+		if err := s.create(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// byKey 返回使用该幂等键的任务，调用方需持有 s.mu
+func (s *MemoryStore) byKey(key string) *model.Task {
+	for _, task := range s.tasks {
+		if task.IdempotencyKey != nil && *task.IdempotencyKey == key {
+			return task
+		}
+	}
+	return nil
+}
+
+// create 保存新任务，调用方需持有 s.mu
+func (s *MemoryStore) create(task *model.Task) error {
+	if task.ID == uuid.Nil {
+		task.ID = uuid.New()
+	}
+	now := time.Now()
+	if task.CreatedAt.IsZero() {
+		task.CreatedAt = now
+	}
+	if task.UpdatedAt.IsZero() {
+		task.UpdatedAt = now
+	}
+	if task.Status == model.StatusPending {
+		if err := s.writeOutbox(task); err != nil {
+			return err
+		}
+	}
+	stored := *task
+	s.tasks[task.ID] = &stored
+	s.writeEvent(newEvent(nil, task, TaskUpdate{Actor: model.ActorAPI}))
+	return nil
+}
+
+// writeEvent 追加状态变更历史，调用方需持有 s.mu
+func (s *MemoryStore) writeEvent(e *model.TaskEvent) {
+	if e == nil {
+		return
+	}
+	e.ID = uint64(len(s.events) + 1)
+	s.events = append(s.events, *e)
+}
+
+// writeOutbox 追加任务的 outbox 消息，调用方需持有 s.mu
+func (s *MemoryStore) writeOutbox(task *model.Task) error {
+	msg, err := newOutboxMessage(task)
+	if err != nil {
+		return err
+	}
+	s.outboxSeq++
+	msg.ID = s.outboxSeq
+	s.outbox = append(s.outbox, *msg)
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id uuid.UUID) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *task
+	return &copied, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, id uuid.UUID, u TaskUpdate) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !canUpdate(task, u) {
+		copied := *task
+		return &copied, ErrConflict
+	}
+
+	updated := *task
+	applyUpdate(&updated, u)
+	if u.Enqueue {
+		if err := s.writeOutbox(&updated); err != nil {
+			return nil, err
+		}
+	}
+	s.writeEvent(newEvent(task, &updated, u))
+	*task = updated
+	return &updated, nil
+}
+
+// matches 判断任务是否满足 TaskFilter 的条件
+func matches(task *model.Task, f TaskFilter) bool {
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, task.ID) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, task.Status) {
+		return false
+	}
+	if f.Type != "" && task.Type != f.Type {
+		return false
+	}
+	if f.Queue != "" && task.Queue != f.Queue {
+		return false
+	}
+	if f.Priority != nil && task.Priority != *f.Priority {
+		return false
+	}
+	if f.MinPriority != nil && task.Priority < *f.MinPriority {
+		return false
+	}
+	if f.RetryCount != nil && task.RetryCount != *f.RetryCount {
+		return false
+	}
+	if f.MinRetryCount != nil && task.RetryCount < *f.MinRetryCount {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(task.Tags, tag) {
+			return false
+		}
+	}
+	if f.CreatedAfter != nil && task.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !task.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	if f.UpdatedAfter != nil && task.UpdatedAt.Before(*f.UpdatedAfter) {
+		return false
+	}
+	if f.UpdatedBefore != nil && !task.UpdatedAt.Before(*f.UpdatedBefore) {
+		return false
+	}
+	if f.DueBefore != nil && (task.NextRunAt == nil || task.NextRunAt.After(*f.DueBefore)) {
+		return false
+	}
+	if f.ExpiresBefore != nil && (task.ExpiresAt == nil || task.ExpiresAt.After(*f.ExpiresBefore)) {
+		return false
+	}
+	if f.LeaseBefore != nil && (task.LeaseExpiresAt == nil || !task.LeaseExpiresAt.Before(*f.LeaseBefore)) {
+		return false
+	}
+	for _, m := range f.JSON {
+		value := task.Payload
+		if m.Column == "result" {
+			value = task.Result
+		}
+		if text, ok := jsonPathText(value, m.Path); !ok || text != m.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonPathText 返回 path 指向的值的文本形式，与 SQL 实现的比较方式保持一致
+func jsonPathText(value model.JSON, path []string) (string, bool) {
+	for _, key := range path {
+		var obj map[string]json.RawMessage
+		var arr []json.RawMessage
+		switch {
+		case json.Unmarshal(value, &obj) == nil && obj != nil:
+			v, ok := obj[key]
+			if !ok {
+				return "", false
+			}
+			value = model.JSON(v)
+		case json.Unmarshal(value, &arr) == nil:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(arr) {
+				return "", false
+			}
+			value = model.JSON(arr[i])
+		default:
+			return "", false
+		}
+	}
+	if value.IsNull() {
+		return "", false
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return "", false
+	}
+	return model.JSON(compact.Bytes()).String(), true
+}
+
+// compareCursor 按 order 的升序比较两个分页位置
+func compareCursor(a, b TaskCursor, order TaskOrder) int {
+	if order == OrderByPriority && a.Priority != b.Priority {
+		return cmp.Compare(a.Priority, b.Priority)
+	}
+	if c := a.At.Compare(b.At); c != 0 {
+		return c
+	}
+	return slices.Compare(a.ID[:], b.ID[:])
+}
+
+// sortTasks 按 TaskFilter 的排序方式排序，与 SQL 实现的排序保持一致
+func sortTasks(tasks []model.Task, f TaskFilter) {
+	if f.Order == OrderByNextRunAt {
+		sort.SliceStable(tasks, func(i, j int) bool {
+			a, b := tasks[i], tasks[j]
+			if a.NextRunAt == nil || b.NextRunAt == nil {
+				return b.NextRunAt == nil && a.NextRunAt != nil
+			}
+			return a.NextRunAt.Before(*b.NextRunAt)
+		})
+		return
+	}
+	slices.SortFunc(tasks, func(a, b model.Task) int {
+		c := compareCursor(CursorOf(&a, f.Order), CursorOf(&b, f.Order), f.Order)
+		if f.Ascending {
+			return c
+		}
+		return -c
+	})
+}
+
+// afterCursor 判断任务是否排在 f.After 之后
+func afterCursor(task *model.Task, f TaskFilter) bool {
+	if f.After == nil || f.Order == OrderByNextRunAt {
+		return true
+	}
+	c := compareCursor(CursorOf(task, f.Order), *f.After, f.Order)
+	if f.Ascending {
+		return c > 0
+	}
+	return c < 0
+}
+
+// filtered 返回满足条件的任务副本（已排序，未分页），调用方需持有 s.mu
+func (s *MemoryStore) filtered(f TaskFilter) []model.Task {
+	var tasks []model.Task
+	for _, task := range s.tasks {
+		if matches(task, f) && afterCursor(task, f) {
+			tasks = append(tasks, *task)
+		}
+	}
+	sortTasks(tasks, f)
+	return tasks
+}
+
+func (s *MemoryStore) List(ctx context.Context, f TaskFilter) ([]model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := s.filtered(f)
+	if f.Offset >= len(tasks) {
+		return nil, nil
+	}
+	tasks = tasks[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(tasks) {
+		tasks = tasks[:f.Limit]
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) Count(ctx context.Context, f TaskFilter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, task := range s.tasks {
+		if matches(task, f) {
+			n++
+		}
+	}
+	return int64(n), nil
+}
+
+func (s *MemoryStore) Lease(ctx context.Context, now time.Time, limit int) ([]model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := s.filtered(TaskFilter{
+		Statuses:  []model.TaskStatus{model.StatusScheduled, model.StatusRetrying},
+		DueBefore: &now,
+		Order:     OrderByNextRunAt,
+	})
+	if limit > 0 && limit < len(due) {
+		due = due[:limit]
+	}
+
+	for i := range due {
+		task := s.tasks[due[i].ID]
+		task.Status = model.StatusPending
+		task.NextRunAt = nil
+		task.UpdatedAt = now
+		task.Version++
+		if err := s.writeOutbox(task); err != nil {
+			return nil, err
+		}
+		s.writeEvent(newEvent(&due[i], task, TaskUpdate{Actor: model.ActorScheduler}))
+		due[i] = *task
+	}
+	return due, nil
+}
+
+func (s *MemoryStore) RelayOutbox(ctx context.Context, limit int, taskIDs []uuid.UUID, publish PublishFunc) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		msgs    []model.OutboxMessage
+		indexes []int // msgs 中每条消息在 s.outbox 中的位置
+	)
+	for i, msg := range s.outbox {
+		if len(msgs) >= limit {
+			break
+		}
+		if msg.SentAt != nil || (len(taskIDs) > 0 && !slices.Contains(taskIDs, msg.TaskID)) {
+			continue
+		}
+		msgs = append(msgs, msg)
+		indexes = append(indexes, i)
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	sent := 0
+	var firstErr error
+	now := time.Now()
+	for j, err := range publish(msgs) {
+		msg := &s.outbox[indexes[j]]
+		if err != nil {
+			msg.Attempts++
+			msg.LastError = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		msg.SentAt = &now
+		sent++
+	}
+	return sent, firstErr
+}
+
+func (s *MemoryStore) PurgeOutbox(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outbox = slices.DeleteFunc(s.outbox, func(msg model.OutboxMessage) bool {
+		return msg.SentAt != nil && msg.SentAt.Before(before)
+	})
+	return nil
+}
+
+func (s *MemoryStore) RepublishPending(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unsent := make(map[uuid.UUID]bool)
+	for _, msg := range s.outbox {
+		if msg.SentAt == nil {
+			unsent[msg.TaskID] = true
+		}
+	}
+	var pending []*model.Task
+	for _, task := range s.tasks {
+		if task.Status == model.StatusPending && !unsent[task.ID] {
+			pending = append(pending, task)
+		}
+	}
+	slices.SortFunc(pending, func(a, b *model.Task) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for _, task := range pending {
+		if err := s.writeOutbox(task); err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), nil
+}
+
+func (s *MemoryStore) Heartbeat(ctx context.Context, workerID string, ids []uuid.UUID, until time.Time) ([]uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var owned []uuid.UUID
+	for _, id := range ids {
+		task, ok := s.tasks[id]
+		if !ok || task.Status != model.StatusRunning || task.WorkerID != workerID {
+			continue
+		}
+		lease := until
+		beat := now
+		task.LeaseExpiresAt = &lease
+		task.HeartbeatAt = &beat
+		owned = append(owned, id)
+	}
+	return owned, nil
+}
+
+func (s *MemoryStore) History(ctx context.Context, taskID uuid.UUID) ([]model.TaskEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []model.TaskEvent
+	for _, e := range s.events {
+		if e.TaskID == taskID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *MemoryStore) SaveTaskTypes(ctx context.Context, types []model.TaskType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.taskTypes == nil {
+		s.taskTypes = make(map[string]model.TaskType)
+	}
+	for _, t := range types {
+		t.UpdatedAt = time.Now()
+		s.taskTypes[t.Name] = t
+	}
+	return nil
+}
+
+func (s *MemoryStore) TaskTypes(ctx context.Context) ([]model.TaskType, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	types := make([]model.TaskType, 0, len(s.taskTypes))
+	for _, t := range s.taskTypes {
+		types = append(types, t)
+	}
+	slices.SortFunc(types, func(a, b model.TaskType) int { return cmp.Compare(a.Name, b.Name) })
+	return types, nil
+}
+
+func (s *MemoryStore) CreateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scheduleNamed(schedule.Name, schedule.ID) != nil {
+		return ErrNameTaken
+	}
+	if s.schedules == nil {
+		s.schedules = make(map[uuid.UUID]*model.Schedule)
+	}
+	if schedule.ID == uuid.Nil {
+		schedule.ID = uuid.New()
+	}
+	now := time.Now()
+	schedule.CreatedAt, schedule.UpdatedAt = now, now
+	stored := *schedule
+	s.schedules[schedule.ID] = &stored
+	return nil
+}
+
+func (s *MemoryStore) UpdateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.schedules[schedule.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if s.scheduleNamed(schedule.Name, schedule.ID) != nil {
+		return ErrNameTaken
+	}
+	schedule.CreatedAt = existing.CreatedAt
+	schedule.UpdatedAt = time.Now()
+	stored := *schedule
+	s.schedules[schedule.ID] = &stored
+	return nil
+}
+
+// scheduleNamed 返回 ID 不是 except 的同名周期任务，调用方需持有 s.mu
+func (s *MemoryStore) scheduleNamed(name string, except uuid.UUID) *model.Schedule {
+	for _, schedule := range s.schedules {
+		if schedule.Name == name && schedule.ID != except {
+			return schedule
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetSchedule(ctx context.Context, id uuid.UUID) (*model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *schedule
+	return &copied, nil
+}
+
+func (s *MemoryStore) ListSchedules(ctx context.Context) ([]model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules := make([]model.Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, *schedule)
+	}
+	slices.SortFunc(schedules, func(a, b model.Schedule) int { return cmp.Compare(a.Name, b.Name) })
+	return schedules, nil
+}
+
+func (s *MemoryStore) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schedules[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.schedules, id)
+	return nil
+}
+
+func (s *MemoryStore) DueSchedules(ctx context.Context, now time.Time) ([]model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []model.Schedule
+	for _, schedule := range s.schedules {
+		if schedule.Enabled && !schedule.NextRunAt.After(now) {
+			due = append(due, *schedule)
+		}
+	}
+	slices.SortFunc(due, func(a, b model.Schedule) int { return a.NextRunAt.Compare(b.NextRunAt) })
+	return due, nil
+}
+
+func (s *MemoryStore) FireSchedule(ctx context.Context, run ScheduleRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule, ok := s.schedules[run.ScheduleID]
+	if !ok || !schedule.NextRunAt.Equal(run.ScheduledTime) {
+		return ErrConflict
+	}
+	lastTaskID := run.LastTaskID
+	if run.Task != nil {
+		if run.Task.IdempotencyKey != nil && s.byKey(*run.Task.IdempotencyKey) != nil {
+			return ErrDuplicate
+		}
+		if err := s.create(run.Task); err != nil {
+			return err
+		}
+		lastTaskID = &run.Task.ID
+	}
+	scheduledTime := run.ScheduledTime
+	schedule.NextRunAt = run.NextRunAt
+	schedule.LastRunAt = &scheduledTime
+	if lastTaskID != nil {
+		id := *lastTaskID
+		schedule.LastTaskID = &id
+	}
+	schedule.UpdatedAt = time.Now()
+	return nil
+}
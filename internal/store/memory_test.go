@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestCompareCursor(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	idA := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	idB := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+
+	tests := []struct {
+		name  string
+		a, b  TaskCursor
+		order TaskOrder
+		want  int
+	}{
+		{"priority decides first", TaskCursor{Priority: 9, At: t0, ID: idA}, TaskCursor{Priority: 1, At: t0.Add(time.Hour), ID: idB}, OrderByPriority, 1},
+		{"same priority compares time", TaskCursor{Priority: 5, At: t0, ID: idB}, TaskCursor{Priority: 5, At: t0.Add(time.Second), ID: idA}, OrderByPriority, -1},
+		{"same priority and time compares id", TaskCursor{Priority: 5, At: t0, ID: idA}, TaskCursor{Priority: 5, At: t0, ID: idB}, OrderByPriority, -1},
+		{"created ignores priority", TaskCursor{Priority: 9, At: t0, ID: idA}, TaskCursor{Priority: 1, At: t0.Add(time.Hour), ID: idA}, OrderByCreated, -1},
+		{"updated ignores priority", TaskCursor{Priority: 1, At: t0.Add(time.Hour), ID: idA}, TaskCursor{Priority: 9, At: t0, ID: idA}, OrderByUpdated, 1},
+		{"same time compares id", TaskCursor{At: t0, ID: idB}, TaskCursor{At: t0, ID: idA}, OrderByCreated, 1},
+		{"equal", TaskCursor{Priority: 3, At: t0, ID: idA}, TaskCursor{Priority: 3, At: t0, ID: idA}, OrderByPriority, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareCursor(tt.a, tt.b, tt.order); got != tt.want {
+				t.Errorf("compareCursor() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAfterCursor(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	id := uuid.MustParse("00000000-0000-0000-0000-000000000005")
+	cursor := &TaskCursor{Priority: 5, At: t0, ID: id}
+	task := func(priority int, at time.Time, id string) *model.Task {
+		return &model.Task{ID: uuid.MustParse(id), Priority: priority, CreatedAt: at, UpdatedAt: at}
+	}
+
+	tests := []struct {
+		name string
+		task *model.Task
+		f    TaskFilter
+		want bool
+	}{
+		{"no cursor", task(0, t0, "00000000-0000-0000-0000-000000000001"), TaskFilter{}, true},
+		{"lower priority comes after in desc", task(4, t0.Add(time.Hour), "00000000-0000-0000-0000-000000000009"), TaskFilter{After: cursor}, true},
+		{"higher priority comes before in desc", task(6, t0, "00000000-0000-0000-0000-000000000001"), TaskFilter{After: cursor}, false},
+		{"higher priority comes after in asc", task(6, t0, "00000000-0000-0000-0000-000000000001"), TaskFilter{After: cursor, Ascending: true}, true},
+		{"tie broken by smaller id in desc", task(5, t0, "00000000-0000-0000-0000-000000000004"), TaskFilter{After: cursor}, true},
+		{"tie broken by larger id in asc", task(5, t0, "00000000-0000-0000-0000-000000000006"), TaskFilter{After: cursor, Ascending: true}, true},
+		{"cursor task itself is excluded", task(5, t0, id.String()), TaskFilter{After: cursor}, false},
+		{"cursor task itself is excluded in asc", task(5, t0, id.String()), TaskFilter{After: cursor, Ascending: true}, false},
+		{"older task comes after by created", task(9, t0.Add(-time.Second), "00000000-0000-0000-0000-000000000009"), TaskFilter{Order: OrderByCreated, After: cursor}, true},
+		{"next_run_at ignores cursor", task(9, t0, "00000000-0000-0000-0000-000000000009"), TaskFilter{Order: OrderByNextRunAt, After: cursor}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := afterCursor(tt.task, tt.f); got != tt.want {
+				t.Errorf("afterCursor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestListKeysetPagination 逐页读取的结果与一次读取全部任务的顺序相同，排序键相同的任务不会重复或遗漏
+func TestListKeysetPagination(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 17 {
+		// 优先级和时间大量重复，只能靠 id 区分先后
+		at := t0.Add(time.Duration(i%4) * time.Minute)
+		task := &model.Task{
+			Type:      "email",
+			Payload:   model.JSON(`{}`),
+			Status:    model.StatusScheduled,
+			Priority:  i % 3,
+			CreatedAt: at,
+			UpdatedAt: at.Add(time.Duration(i%2) * time.Hour),
+		}
+		if err := s.Create(ctx, task); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	orders := map[string]TaskOrder{"priority": OrderByPriority, "created": OrderByCreated, "updated": OrderByUpdated}
+	for name, order := range orders {
+		for _, asc := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s asc=%v", name, asc), func(t *testing.T) {
+				all, err := s.List(ctx, TaskFilter{Order: order, Ascending: asc})
+				if err != nil {
+					t.Fatalf("List() error = %v", err)
+				}
+				for i := 1; i < len(all); i++ {
+					c := compareCursor(CursorOf(&all[i-1], order), CursorOf(&all[i], order), order)
+					if (asc && c >= 0) || (!asc && c <= 0) {
+						t.Fatalf("tasks %d and %d out of order", i-1, i)
+					}
+				}
+
+				var paged []model.Task
+				f := TaskFilter{Order: order, Ascending: asc, Limit: 5}
+				for {
+					page, err := s.List(ctx, f)
+					if err != nil {
+						t.Fatalf("List() error = %v", err)
+					}
+					paged = append(paged, page...)
+					if len(page) < f.Limit {
+						break
+					}
+					cursor := CursorOf(&page[len(page)-1], order)
+					f.After = &cursor
+				}
+				if !slices.EqualFunc(paged, all, func(a, b model.Task) bool { return a.ID == b.ID }) {
+					t.Errorf("paged results differ from full listing: got %d tasks, want %d", len(paged), len(all))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound 任务不存在
+	ErrNotFound = errors.New("task not found")
+	// ErrConflict 条件更新时任务不处于期望的状态或版本，或状态机不允许该转换
+	ErrConflict = errors.New("task status or version does not match")
+	// ErrDuplicate 幂等键已被窗口期内的任务使用
+	ErrDuplicate = errors.New("idempotency key already used")
+	// ErrNameTaken 周期任务名称已被使用
+	ErrNameTaken = errors.New("schedule name already used")
+)
+
+// TaskStore 任务及其 outbox 消息的存储
+//
+// 状态为 pending 的任务在写入时会在同一事务中写入 outbox 消息，由 RelayOutbox 发布到 broker，
+// 因此任务记录和队列消息不会出现只有一边成功的情况。
+type TaskStore interface {
+	// Create 保存新任务，ID 为空时自动生成；pending 任务同时写入 outbox
+	Create(ctx context.Context, task *model.Task) error
+	// CreateIdempotent 与 Create 相同，但 since 之后已有相同 IdempotencyKey 的任务时返回 ErrDuplicate 和该任务
+	//
+	// 幂等键在 since 之前使用过时，旧任务的键被清空，新任务接管该键。
+	CreateIdempotent(ctx context.Context, task *model.Task, since time.Time) (*model.Task, error)
+	// CreateBatch 在一个事务中保存多个新任务，任何一个失败时全部回滚
+	CreateBatch(ctx context.Context, tasks []*model.Task) error
+	// Get 按 ID 读取任务，不存在时返回 ErrNotFound
+	Get(ctx context.Context, id uuid.UUID) (*model.Task, error)
+	// Update 按条件更新任务并返回更新后的任务，每次更新任务的版本加 1
+	//
+	// 任务不处于 u.From 中的状态、版本不等于 u.Version、租约没有在 u.LeaseExpiredBefore 之前到期、
+	// 或状态机不允许转换到 u.Status 时，返回 ErrConflict 和任务的当前值。
+	// 状态变化时在同一事务中写入一条 TaskEvent；离开 running 状态时清空租约。
+	Update(ctx context.Context, id uuid.UUID, u TaskUpdate) (*model.Task, error)
+	// List 按条件查询任务
+	List(ctx context.Context, f TaskFilter) ([]model.Task, error)
+	// Count 返回符合条件的任务数，忽略分页和排序
+	Count(ctx context.Context, f TaskFilter) (int64, error)
+	// Lease 抢占 next_run_at 已到期的定时和重试任务，置为 pending 并写入 outbox
+	//
+	// 多个实例同时调用时每个任务只会被一个实例抢到。
+	Lease(ctx context.Context, now time.Time, limit int) ([]model.Task, error)
+
+	// RelayOutbox 按写入顺序取出最多 limit 条尚未发布的 outbox 消息，一次交给 publish，发布成功的消息标记为已发布
+	//
+	// 指定 taskIDs 时只处理这些任务的消息。发布失败的消息记录错误留到下一轮，返回已发布的消息数和第一个发布错误。
+	RelayOutbox(ctx context.Context, limit int, taskIDs []uuid.UUID, publish PublishFunc) (int, error)
+	// PurgeOutbox 删除 before 之前已发布的 outbox 消息
+	PurgeOutbox(ctx context.Context, before time.Time) error
+	// RepublishPending 为没有未发布 outbox 消息的 pending 任务重新写入 outbox 消息，返回写入的消息数
+	//
+	// 用于进程内 broker 重启后找回已经发布、但随进程退出丢失的消息；Worker 按版本跳过重复投递。
+	RepublishPending(ctx context.Context) (int, error)
+
+	// Heartbeat 把 workerID 持有的 running 任务的租约延长到 until，不改变任务版本
+	//
+	// 返回 ids 中仍由 workerID 持有的任务，其余任务已被回收或已结束。
+	Heartbeat(ctx context.Context, workerID string, ids []uuid.UUID, until time.Time) ([]uuid.UUID, error)
+	// History 按发生顺序返回任务的状态变更历史
+	History(ctx context.Context, taskID uuid.UUID) ([]model.TaskEvent, error)
+
+	// SaveTaskTypes 写入 Worker 注册的任务类型，已存在的类型用新设置覆盖
+	SaveTaskTypes(ctx context.Context, types []model.TaskType) error
+	// TaskTypes 返回所有 Worker 注册过的任务类型
+	TaskTypes(ctx context.Context) ([]model.TaskType, error)
+
+	// CreateSchedule 保存新的周期任务，名称已被使用时返回 ErrNameTaken
+	CreateSchedule(ctx context.Context, schedule *model.Schedule) error
+	// UpdateSchedule 用 schedule 的内容覆盖已有的周期任务，不存在时返回 ErrNotFound，名称已被使用时返回 ErrNameTaken
+	UpdateSchedule(ctx context.Context, schedule *model.Schedule) error
+	// GetSchedule 按 ID 读取周期任务，不存在时返回 ErrNotFound
+	GetSchedule(ctx context.Context, id uuid.UUID) (*model.Schedule, error)
+	// ListSchedules 按名称顺序返回所有周期任务
+	ListSchedules(ctx context.Context) ([]model.Schedule, error)
+	// DeleteSchedule 删除周期任务，不存在时返回 ErrNotFound
+	DeleteSchedule(ctx context.Context, id uuid.UUID) error
+	// DueSchedules 按 next_run_at 顺序返回 now 之前到期的已启用周期任务
+	DueSchedules(ctx context.Context, now time.Time) ([]model.Schedule, error)
+	// FireSchedule 抢占周期任务的一次触发，并在同一事务中创建本次触发的任务
+	//
+	// 周期任务的 next_run_at 已不等于 run.ScheduledTime 时说明已被其他实例触发，返回 ErrConflict 且不创建任务；
+	// 创建任务失败时抢占也一起回滚，下一轮调度会重新触发。
+	FireSchedule(ctx context.Context, run ScheduleRun) error
+}
+
+// PublishFunc 发布一组 outbox 消息，返回与 msgs 一一对应的错误，nil 表示该消息已被 broker 确认
+type PublishFunc func(msgs []model.OutboxMessage) []error
+
+// ScheduleRun 周期任务的一次触发
+type ScheduleRun struct {
+	ScheduleID    uuid.UUID
+	ScheduledTime time.Time // 本次触发的计划时间，即抢占前的 next_run_at
+	NextRunAt     time.Time // 下一次触发时间
+
+	// Task 非 nil 时在同一事务中创建该任务并记录为 last_task_id；pending 任务同时写入 outbox
+	Task *model.Task
+	// LastTaskID 在 Task 为 nil 时记录为 last_task_id，都为 nil 时只推进触发时间（跳过本次触发）
+	LastTaskID *uuid.UUID
+}
+
+// TaskUpdate 任务更新内容，nil 字段不更新
+type TaskUpdate struct {
+	Status         *model.TaskStatus
+	Result         *model.JSON
+	Summary        *string
+	RetryCount     *int
+	LastError      *string
+	ErrorKind      *model.ErrorKind
+	NextRunAt      *time.Time
+	ClearNextRunAt bool // 清空 next_run_at
+	WorkerID       *string
+	LeaseExpiresAt *time.Time // 同时作为心跳时间写入 heartbeat_at
+
+	// From 非空时只有任务处于其中某个状态才会更新
+	From []model.TaskStatus
+	// Version 非 nil 时只有任务的版本等于该值才会更新
+	Version *int64
+	// LeaseExpiredBefore 非 nil 时只有租约在该时间之前到期的任务才会更新
+	LeaseExpiredBefore *time.Time
+	// Enqueue 为 true 时在同一事务中写入 outbox 消息
+	Enqueue bool
+
+	// Actor 和 Reason 记录在状态变更历史中，Actor 为空时使用任务的 Worker ID
+	Actor  string
+	Reason string
+}
+
+// canUpdate 判断任务当前值是否满足 u 的更新条件
+func canUpdate(task *model.Task, u TaskUpdate) bool {
+	from, ok := allowedFrom(u)
+	if !ok || (len(from) > 0 && !slices.Contains(from, task.Status)) {
+		return false
+	}
+	if u.Version != nil && task.Version != *u.Version {
+		return false
+	}
+	if u.LeaseExpiredBefore != nil && (task.LeaseExpiresAt == nil || !task.LeaseExpiresAt.Before(*u.LeaseExpiredBefore)) {
+		return false
+	}
+	return true
+}
+
+// applyUpdate 把 TaskUpdate 中的非 nil 字段写入 task，并把版本加 1
+func applyUpdate(task *model.Task, u TaskUpdate) {
+	task.UpdatedAt = time.Now()
+	task.Version++
+	if u.Status != nil {
+		task.Status = *u.Status
+		if task.Status != model.StatusRunning {
+			task.WorkerID = ""
+			task.LeaseExpiresAt = nil
+			task.HeartbeatAt = nil
+		}
+	}
+	if u.Result != nil {
+		task.Result = *u.Result
+	}
+	if u.Summary != nil {
+		task.Summary = *u.Summary
+	}
+	if u.RetryCount != nil {
+		task.RetryCount = *u.RetryCount
+	}
+	if u.LastError != nil {
+		task.LastError = *u.LastError
+	}
+	if u.ErrorKind != nil {
+		task.ErrorKind = *u.ErrorKind
+	}
+	if u.NextRunAt != nil {
+		runAt := *u.NextRunAt
+		task.NextRunAt = &runAt
+	}
+	if u.ClearNextRunAt {
+		task.NextRunAt = nil
+	}
+	if u.WorkerID != nil {
+		task.WorkerID = *u.WorkerID
+	}
+	if u.LeaseExpiresAt != nil {
+		until := *u.LeaseExpiresAt
+		now := task.UpdatedAt
+		task.LeaseExpiresAt = &until
+		task.HeartbeatAt = &now
+	}
+}
+
+// newEvent 构造状态变更历史，状态没有变化时返回 nil
+func newEvent(before, after *model.Task, u TaskUpdate) *model.TaskEvent {
+	if before != nil && before.Status == after.Status {
+		return nil
+	}
+	e := &model.TaskEvent{
+		TaskID:    after.ID,
+		To:        after.Status,
+		Actor:     u.Actor,
+		Message:   u.Reason,
+		CreatedAt: after.UpdatedAt,
+	}
+	if before != nil {
+		e.From = before.Status
+		if e.Actor == "" {
+			e.Actor = before.WorkerID
+		}
+	}
+	if e.Actor == "" {
+		e.Actor = after.WorkerID
+	}
+	if e.Message == "" && u.LastError != nil {
+		e.Message = *u.LastError
+	}
+	return e
+}
+
+// allowedFrom 返回允许更新的起始状态：u.From 中状态机允许转换到 u.Status 的状态
+//
+// 没有设置 u.Status 时直接返回 u.From；ok 为 false 表示没有任何状态可以转换到 u.Status。
+func allowedFrom(u TaskUpdate) (from []model.TaskStatus, ok bool) {
+	if u.Status == nil {
+		return u.From, true
+	}
+	if len(u.From) == 0 {
+		from = model.TransitionSources(*u.Status)
+	} else {
+		for _, status := range u.From {
+			if status.CanTransitionTo(*u.Status) {
+				from = append(from, status)
+			}
+		}
+	}
+	return from, len(from) > 0
+}
+
+// TaskOrder 任务列表的排序方式
+type TaskOrder int
+
+const (
+	OrderByPriority  TaskOrder = iota // 优先级高的在前，同优先级新创建的在前
+	OrderByUpdated                    // 最近更新的在前
+	OrderByNextRunAt                  // next_run_at 早的在前
+	OrderByCreated                    // 新创建的在前
+)
+
+// TaskCursor 键集分页的位置，即上一页最后一个任务的排序键，id 用于区分排序键相同的任务
+type TaskCursor struct {
+	Priority int       `json:"p,omitempty"`
+	At       time.Time `json:"t"` // created_at 或 updated_at，取决于排序方式
+	ID       uuid.UUID `json:"id"`
+}
+
+// CursorOf 返回 task 在 order 排序下的分页位置，OrderByNextRunAt 不支持键集分页
+func CursorOf(task *model.Task, order TaskOrder) TaskCursor {
+	c := TaskCursor{At: task.CreatedAt, ID: task.ID}
+	switch order {
+	case OrderByPriority:
+		c.Priority = task.Priority
+	case OrderByUpdated:
+		c.At = task.UpdatedAt
+	}
+	return c
+}
+
+// JSONMatch 按 JSON 路径过滤：Column 列中 Path 指向的值的文本形式等于 Value
+//
+// 字符串比较其内容，数字、布尔值比较其 JSON 文本；路径不存在或值为 null 时不匹配。
+type JSONMatch struct {
+	Column string   // payload 或 result
+	Path   []string // 对象的键，全是数字时作为数组下标
+	Value  string
+}
+
+// TaskFilter 任务查询条件，零值字段不参与过滤
+type TaskFilter struct {
+	IDs           []uuid.UUID
+	Statuses      []model.TaskStatus
+	Type          string
+	Queue         string
+	Priority      *int
+	MinPriority   *int
+	RetryCount    *int
+	MinRetryCount *int
+	Tags          []string    // 同时带有所有标签
+	CreatedAfter  *time.Time  // created_at >= CreatedAfter
+	CreatedBefore *time.Time  // created_at < CreatedBefore
+	UpdatedAfter  *time.Time  // updated_at >= UpdatedAfter
+	UpdatedBefore *time.Time  // updated_at < UpdatedBefore
+	DueBefore     *time.Time  // next_run_at <= DueBefore
+	ExpiresBefore *time.Time  // expires_at <= ExpiresBefore
+	LeaseBefore   *time.Time  // lease_expires_at < LeaseBefore
+	JSON          []JSONMatch // payload/result 中指定路径的值
+
+	Order     TaskOrder
+	Ascending bool        // 反转 Order 的方向，对 OrderByNextRunAt 无效
+	After     *TaskCursor // 只返回排在该位置之后的任务，与 Offset 二选一
+	Limit     int         // 0 表示不限制
+	Offset    int
+}
+
+// Default 当前进程使用的 TaskStore
+var Default TaskStore
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// newSQLiteStore 返回使用独立内存数据库的 gorm 实现
+func newSQLiteStore(t *testing.T) TaskStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// 单连接才能让 :memory: 数据库在连接间共享
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	err = db.AutoMigrate(&model.Task{}, &model.Schedule{}, &model.OutboxMessage{}, &model.TaskEvent{}, &model.TaskTag{}, &model.TaskType{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewGormStore(db)
+}
+
+// testStores 返回内存实现和 SQLite 上的 gorm 实现，同一个测试对两者运行以保证行为一致
+func testStores(t *testing.T) map[string]TaskStore {
+	return map[string]TaskStore{"memory": NewMemoryStore(), "sqlite": newSQLiteStore(t)}
+}
+
+// TestNonUTCTimes 带时区偏移的时间按时刻比较，而不是按保存的文本比较
+func TestNonUTCTimes(t *testing.T) {
+	ctx := context.Background()
+	shanghai := time.FixedZone("UTC+8", 8*3600)
+	newYork := time.FixedZone("UTC-5", -5*3600)
+	now := time.Now()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			due := now.Add(-time.Minute).In(shanghai)
+			notDue := now.Add(time.Minute).In(newYork)
+			dueTask := &model.Task{Type: "email", Payload: model.JSON(`{}`), Status: model.StatusScheduled, NextRunAt: &due}
+			laterTask := &model.Task{Type: "email", Payload: model.JSON(`{}`), Status: model.StatusScheduled, NextRunAt: &notDue}
+			for _, task := range []*model.Task{dueTask, laterTask} {
+				if err := s.Create(ctx, task); err != nil {
+					t.Fatalf("Create() error = %v", err)
+				}
+			}
+
+			leased, err := s.Lease(ctx, now.In(newYork), 10)
+			if err != nil {
+				t.Fatalf("Lease() error = %v", err)
+			}
+			if len(leased) != 1 || leased[0].ID != dueTask.ID {
+				t.Fatalf("Lease() leased %d tasks, want only the task due at %s", len(leased), due)
+			}
+
+			schedule := &model.Schedule{Name: "daily", CronExpr: "@daily", Timezone: "Asia/Shanghai", Type: "email", Enabled: true, NextRunAt: due}
+			if err := s.CreateSchedule(ctx, schedule); err != nil {
+				t.Fatalf("CreateSchedule() error = %v", err)
+			}
+			schedules, err := s.DueSchedules(ctx, now.In(newYork))
+			if err != nil {
+				t.Fatalf("DueSchedules() error = %v", err)
+			}
+			if len(schedules) != 1 {
+				t.Fatalf("DueSchedules() returned %d schedules, want 1", len(schedules))
+			}
+
+			// 抢占条件使用另一个时区表示的同一时刻
+			run := ScheduleRun{ScheduleID: schedule.ID, ScheduledTime: schedules[0].NextRunAt.In(newYork), NextRunAt: now.Add(time.Hour).In(shanghai)}
+			if err := s.FireSchedule(ctx, run); err != nil {
+				t.Fatalf("FireSchedule() error = %v", err)
+			}
+			if schedules, _ := s.DueSchedules(ctx, now); len(schedules) != 0 {
+				t.Errorf("schedule is still due after firing")
+			}
+		})
+	}
+}
+
+// TestRelayOutboxPartialFailure 一批消息中发布失败的消息留到下一轮，其余消息标记为已发布
+func TestRelayOutboxPartialFailure(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var tasks []*model.Task
+			for range 4 {
+				task := &model.Task{Type: "email", Payload: model.JSON(`{}`), Status: model.StatusPending}
+				tasks = append(tasks, task)
+			}
+			if err := s.CreateBatch(ctx, tasks); err != nil {
+				t.Fatalf("CreateBatch() error = %v", err)
+			}
+
+			failed := tasks[1].ID
+			calls := 0
+			n, err := s.RelayOutbox(ctx, 10, nil, func(msgs []model.OutboxMessage) []error {
+				calls++
+				if len(msgs) != len(tasks) {
+					t.Errorf("publish got %d messages, want %d in one call", len(msgs), len(tasks))
+				}
+				errs := make([]error, len(msgs))
+				for i, msg := range msgs {
+					if msg.TaskID == failed {
+						errs[i] = errors.New("broker rejected message")
+					}
+				}
+				return errs
+			})
+			if n != 3 || err == nil || calls != 1 {
+				t.Fatalf("RelayOutbox() = %d, %v after %d calls, want 3 and the publish error after 1 call", n, err, calls)
+			}
+
+			// 下一轮只会重新发布失败的消息
+			var retried []uuid.UUID
+			n, err = s.RelayOutbox(ctx, 10, nil, func(msgs []model.OutboxMessage) []error {
+				for _, msg := range msgs {
+					retried = append(retried, msg.TaskID)
+					if msg.Attempts != 1 || msg.LastError == "" {
+						t.Errorf("retried message attempts = %d, last_error = %q, want 1 and the error", msg.Attempts, msg.LastError)
+					}
+				}
+				return make([]error, len(msgs))
+			})
+			if n != 1 || err != nil || len(retried) != 1 || retried[0] != failed {
+				t.Errorf("second RelayOutbox() = %d, %v, republished %v, want only %s", n, err, retried, failed)
+			}
+		})
+	}
+}
+
+// TestUpdateConditions 条件不满足时返回 ErrConflict 和任务的当前值，任务不被修改
+func TestUpdateConditions(t *testing.T) {
+	ctx := context.Background()
+	running, success, pending := model.StatusRunning, model.StatusSuccess, model.StatusPending
+	stale := int64(0)
+
+	tests := []struct {
+		name     string
+		u        TaskUpdate
+		conflict bool
+	}{
+		{"legal transition", TaskUpdate{Status: &running}, false},
+		{"illegal transition", TaskUpdate{Status: &success}, true},
+		{"transition to the same status", TaskUpdate{Status: &pending}, true},
+		{"stale version", TaskUpdate{Status: &running, Version: &stale}, true},
+		{"unexpected status", TaskUpdate{Status: &running, From: []model.TaskStatus{model.StatusScheduled}}, true},
+		{"field update in expected status", TaskUpdate{Summary: new(string), From: []model.TaskStatus{model.StatusPending}}, false},
+	}
+	for name, s := range testStores(t) {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				task := &model.Task{Type: "email", Payload: model.JSON(`{}`), Status: model.StatusPending, Version: 1}
+				if err := s.Create(ctx, task); err != nil {
+					t.Fatalf("Create() error = %v", err)
+				}
+
+				got, err := s.Update(ctx, task.ID, tt.u)
+				if tt.conflict != errors.Is(err, ErrConflict) || (!tt.conflict && err != nil) {
+					t.Fatalf("Update() error = %v, want conflict %v", err, tt.conflict)
+				}
+				if got == nil {
+					t.Fatal("Update() returned no task")
+				}
+				wantVersion := task.Version + 1
+				if tt.conflict {
+					// 冲突时返回的是当前值
+					wantVersion = task.Version
+				}
+				if got.Version != wantVersion {
+					t.Errorf("returned version = %d, want %d", got.Version, wantVersion)
+				}
+
+				stored, err := s.Get(ctx, task.ID)
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				if stored.Version != wantVersion || (tt.conflict && stored.Status != model.StatusPending) {
+					t.Errorf("stored task = %s v%d, want v%d", stored.Status, stored.Version, wantVersion)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// utcConnPool 在所有 SQL 参数交给驱动之前把时间转换为 UTC
+//
+// SQLite 把时间保存为带时区偏移的文本，next_run_at <= ? 之类的条件按字符串比较：
+// 偏移不同的两个时间即使先后顺序确定，比较结果也可能相反。统一使用 UTC 后文本顺序与时间顺序一致。
+// PostgreSQL 的 timestamptz 本身按时刻比较，转换不改变结果。
+type utcConnPool struct {
+	gorm.ConnPool
+}
+
+// utcTx 事务中的 utcConnPool，gorm 通过 TxCommitter 判断当前是否处于事务中，并要求它是指针
+type utcTx struct {
+	utcConnPool
+	tx gorm.TxCommitter
+}
+
+// withUTC 返回所有 SQL 参数中的时间都会被转换为 UTC 的连接
+func withUTC(db *gorm.DB) *gorm.DB {
+	// 指定 Context 时 Session 会复制 Statement，不影响调用方的 db
+	db = db.Session(&gorm.Session{Context: context.Background(), NewDB: true})
+	db.Statement.ConnPool = &utcConnPool{db.Statement.ConnPool}
+	return db
+}
+
+// utcArgs 把参数中的 time.Time 和 *time.Time 转换为 UTC，其他参数原样返回
+func utcArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case *time.Time:
+			if v != nil {
+				utc := v.UTC()
+				args[i] = &utc
+			}
+		}
+	}
+	return args
+}
+
+func (p *utcConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.ConnPool.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (p *utcConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.ConnPool.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (p *utcConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.ConnPool.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+func (p *utcConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var (
+		tx  gorm.ConnPool
+		err error
+	)
+	switch beginner := p.ConnPool.(type) {
+	case gorm.TxBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	default:
+		return nil, gorm.ErrInvalidTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+	committer, ok := tx.(gorm.TxCommitter)
+	if !ok {
+		return nil, gorm.ErrInvalidTransaction
+	}
+	return &utcTx{utcConnPool: utcConnPool{tx}, tx: committer}, nil
+}
+
+func (t *utcTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *utcTx) Rollback() error {
+	return t.tx.Rollback()
+}
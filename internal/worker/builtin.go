@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"log"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+)
+
+// RegisterBuiltins 注册项目自带的示例任务处理器
+func RegisterBuiltins(r *Registry) {
+	r.RegisterFunc("email", processEmailTask)
+	r.RegisterFunc("data_sync", processDataSyncTask, WithConcurrency(5))
+}
+
+// BuiltinTaskTypes 返回内置任务类型的设置，API 在还没有 Worker 注册时以此校验内置类型
+func BuiltinTaskTypes() []model.TaskType {
+	r := NewRegistry()
+	RegisterBuiltins(r)
+	return r.TaskTypes()
+}
+
+func processEmailTask(ctx context.Context, task *model.Task) error {
+	log.Printf("📧 Processing email task: %s\n", task.Payload)
+	// 模拟邮件发送逻辑
+	return nil
+}
+
+func processDataSyncTask(ctx context.Context, task *model.Task) error {
+	log.Printf("🔄 Processing data sync task: %s\n", task.Payload)
+	// 模拟数据同步逻辑
+	return nil
+}
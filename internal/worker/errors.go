@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+)
+
+// TaskError 携带错误分类的处理器错误，Worker 根据分类决定是否重试以及等待多久
+type TaskError struct {
+	Kind  model.ErrorKind
+	Delay time.Duration // 建议的重试等待时间，0 表示使用重试策略计算的时间
+	Err   error
+}
+
+func (e *TaskError) Error() string {
+	return string(e.Kind) + ": " + e.Err.Error()
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent 标记为永久错误（如参数校验失败），任务直接进入死信，不再重试
+func Permanent(err error) error {
+	return &TaskError{Kind: model.ErrorPermanent, Err: err}
+}
+
+// RetryAfter 标记为可重试错误，并建议在 delay 之后重试
+func RetryAfter(err error, delay time.Duration) error {
+	return &TaskError{Kind: model.ErrorRetryable, Delay: delay, Err: err}
+}
+
+// RateLimited 标记为被下游限流，delay 之后重试，不计入重试次数
+func RateLimited(err error, delay time.Duration) error {
+	return &TaskError{Kind: model.ErrorRateLimited, Delay: delay, Err: err}
+}
+
+// classify 返回错误分类和建议的等待时间，未分类的错误视为普通可重试错误
+func classify(err error) (model.ErrorKind, time.Duration) {
+	var taskErr *TaskError
+	if errors.As(err, &taskErr) {
+		return taskErr.Kind, taskErr.Delay
+	}
+	return model.ErrorRetryable, 0
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestClassify(t *testing.T) {
+	base := errors.New("smtp unavailable")
+	tests := []struct {
+		name  string
+		err   error
+		kind  model.ErrorKind
+		delay time.Duration
+	}{
+		{"plain error", base, model.ErrorRetryable, 0},
+		{"permanent", Permanent(base), model.ErrorPermanent, 0},
+		{"wrapped permanent", fmt.Errorf("send mail: %w", Permanent(base)), model.ErrorPermanent, 0},
+		{"retry after", RetryAfter(base, 5*time.Second), model.ErrorRetryable, 5 * time.Second},
+		{"rate limited", RateLimited(base, time.Minute), model.ErrorRateLimited, time.Minute},
+		{"timed out", &TaskError{Kind: model.ErrorTimedOut, Err: context.DeadlineExceeded}, model.ErrorTimedOut, 0},
+		{"outermost classification wins", Permanent(RetryAfter(base, time.Second)), model.ErrorPermanent, 0},
+		{"context cancelled is retryable", context.Canceled, model.ErrorRetryable, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, delay := classify(tt.err)
+			if kind != tt.kind || delay != tt.delay {
+				t.Errorf("classify() = %s, %v, want %s, %v", kind, delay, tt.kind, tt.delay)
+			}
+		})
+	}
+}
+
+func TestTaskErrorUnwrap(t *testing.T) {
+	base := errors.New("smtp unavailable")
+	err := RateLimited(base, time.Second)
+	if !errors.Is(err, base) {
+		t.Error("errors.Is(RateLimited(base), base) = false")
+	}
+	if got, want := err.Error(), "rate_limited: smtp unavailable"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+// TestTypedHandlerInvalidPayload 无法解码的 payload 重试也不会成功，返回永久错误
+func TestTypedHandlerInvalidPayload(t *testing.T) {
+	type payload struct {
+		To string `json:"to"`
+	}
+	called := false
+	h := TypedHandler(func(ctx context.Context, task *model.Task, p payload) error {
+		called = true
+		return nil
+	})
+
+	err := h.ProcessTask(context.Background(), &model.Task{ID: uuid.New(), Type: "email", Payload: model.JSON(`{"to":42}`)})
+	if kind, _ := classify(err); kind != model.ErrorPermanent || called {
+		t.Errorf("ProcessTask() error = %v (%s), handler called = %v, want a permanent error without calling the handler", err, kind, called)
+	}
+}
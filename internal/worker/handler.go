@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+)
+
+// Handler 任务处理器接口，每种任务类型对应一个 Handler
+//
+// ctx 在任务被取消（context.Cause 为 ErrTaskCancelled）或 Worker 停止超时时被取消，
+// 耗时较长的 Handler 应该检查 ctx 并尽快返回。
+type Handler interface {
+	ProcessTask(ctx context.Context, task *model.Task) error
+}
+
+// HandlerFunc 允许直接把普通函数当作 Handler 使用
+type HandlerFunc func(ctx context.Context, task *model.Task) error
+
+// ProcessTask 调用 f(ctx, task)
+func (f HandlerFunc) ProcessTask(ctx context.Context, task *model.Task) error {
+	return f(ctx, task)
+}
+
+// TypedHandler 把 payload 解码为 T 后交给 fn 处理，payload 无法解码时返回永久错误，不再重试
+//
+//	r.Register("email", worker.TypedHandler(func(ctx context.Context, task *model.Task, p EmailPayload) error { ... }))
+func TypedHandler[T any](fn func(ctx context.Context, task *model.Task, payload T) error) HandlerFunc {
+	return func(ctx context.Context, task *model.Task) error {
+		var payload T
+		if err := task.DecodePayload(&payload); err != nil {
+			return Permanent(fmt.Errorf("invalid %s payload: %w", task.Type, err))
+		}
+		return fn(ctx, task, payload)
+	}
+}
+
+// Middleware 包装 Handler，用于日志、恢复 panic、监控等横切逻辑
+type Middleware func(Handler) Handler
+
+// Chain 按顺序组合中间件，第一个中间件位于最外层
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
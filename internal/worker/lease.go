@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/service"
+	"github.com/google/uuid"
+)
+
+// ErrLeaseLost 任务的租约已被回收时作为 Handler context 的取消原因
+var ErrLeaseLost = errors.New("task lease lost")
+
+// newWorkerID 生成 Worker 实例的唯一标识，记录在任务租约和历史中
+func newWorkerID() string {
+	host, _ := os.Hostname()
+	return host + "-" + uuid.NewString()[:8]
+}
+
+// heartbeat 每三分之一租约为正在执行的任务续期，直到 ctx 被取消
+//
+// 续期时发现任务已不属于本 Worker（租约过期被回收，或已被取消），以 ErrLeaseLost 中断 Handler。
+func (w *Worker) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(w.leaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		w.mu.Lock()
+		ids := make([]uuid.UUID, 0, len(w.inflight))
+		for id, t := range w.inflight {
+			if t.version >= 0 {
+				ids = append(ids, id)
+			}
+		}
+		w.mu.Unlock()
+		if len(ids) == 0 {
+			continue
+		}
+
+		owned, err := service.HeartbeatTasks(w.id, ids, time.Now().Add(w.leaseTTL))
+		if err != nil {
+			// 数据库暂时不可用时租约可能仍然有效，下次再续期
+			log.Printf("⚠️ Failed to renew task leases: %v\n", err)
+			continue
+		}
+		for _, id := range ids {
+			if slices.Contains(owned, id) {
+				continue
+			}
+			w.mu.Lock()
+			t, ok := w.inflight[id]
+			w.mu.Unlock()
+			if ok {
+				log.Printf("❌ Lease for task %s lost, interrupting\n", id)
+				t.cancel(ErrLeaseLost)
+			}
+		}
+	}
+}
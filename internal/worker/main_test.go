@@ -0,0 +1,19 @@
+package worker
+
+import (
+	"os"
+	"testing"
+
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/config"
+)
+
+// TestMain 使用进程内的 Redis 运行测试，不依赖外部服务
+func TestMain(m *testing.M) {
+	config.LoadConfig()
+	cache.InitEmbeddedRedis()
+
+	code := m.Run()
+	cache.Close()
+	os.Exit(code)
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+)
+
+// Recover 捕获处理器中的 panic，并转换为普通错误
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, task *model.Task) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("handler panic: %v", r)
+				}
+			}()
+			return next.ProcessTask(ctx, task)
+		})
+	}
+}
+
+// Logging 记录每次任务处理的耗时与结果
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, task *model.Task) error {
+			start := time.Now()
+			err := next.ProcessTask(ctx, task)
+			if err != nil {
+				log.Printf("⚠️ Task %s (%s) returned error after %v: %v\n", task.ID, task.Type, time.Since(start), err)
+			} else {
+				log.Printf("⏱️ Task %s (%s) processed in %v\n", task.ID, task.Type, time.Since(start))
+			}
+			return err
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package worker
+
+import "context"
+
+// pool 限制 Worker 的总并发和按任务类型的并发
+//
+// 每条消息在自己的 goroutine 中等待额度，goroutine 数量受 broker 预取数量限制，
+// 因此某个类型的额度耗尽时，不会占住总额度而阻塞其他类型的任务。
+type pool struct {
+	slots     chan struct{}
+	typeSlots map[string]chan struct{}
+}
+
+func newPool(size int, typeLimits map[string]int) *pool {
+	p := &pool{
+		slots:     make(chan struct{}, size),
+		typeSlots: make(map[string]chan struct{}, len(typeLimits)),
+	}
+	for taskType, limit := range typeLimits {
+		if limit > 0 {
+			p.typeSlots[taskType] = make(chan struct{}, limit)
+		}
+	}
+	return p
+}
+
+// acquire 先获取任务类型额度，再获取总额度；ctx 取消时放弃等待
+func (p *pool) acquire(ctx context.Context, taskType string) error {
+	s, limited := p.typeSlots[taskType]
+	if limited {
+		select {
+		case s <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		if limited {
+			<-s
+		}
+		return ctx.Err()
+	}
+}
+
+func (p *pool) release(taskType string) {
+	<-p.slots
+	if s, ok := p.typeSlots[taskType]; ok {
+		<-s
+	}
+}
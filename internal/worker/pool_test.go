@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPoolLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		limits map[string]int
+		types  []string // 依次获取额度的任务类型，都不释放
+		want   []bool   // 每次获取是否成功
+	}{
+		{"total limit", 2, nil, []string{"email", "sync", "email"}, []bool{true, true, false}},
+		{"type limit", 3, map[string]int{"sync": 1}, []string{"sync", "sync", "email", "email"}, []bool{true, false, true, true}},
+		{"waiting type does not hold total slots", 2, map[string]int{"sync": 1}, []string{"sync", "sync", "sync", "email"}, []bool{true, false, false, true}},
+		{"zero type limit is unlimited", 2, map[string]int{"sync": 0}, []string{"sync", "sync", "sync"}, []bool{true, true, false}},
+		{"type limit above total", 1, map[string]int{"sync": 5}, []string{"sync", "sync"}, []bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPool(tt.size, tt.limits)
+			for i, taskType := range tt.types {
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				got := p.acquire(ctx, taskType) == nil
+				cancel()
+				if got != tt.want[i] {
+					t.Errorf("acquire #%d (%s) = %v, want %v", i, taskType, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestPoolRelease 释放额度后等待中的同类型任务可以继续
+func TestPoolRelease(t *testing.T) {
+	p := newPool(4, map[string]int{"sync": 1})
+	if err := p.acquire(context.Background(), "sync"); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- p.acquire(context.Background(), "sync") }()
+	select {
+	case <-acquired:
+		t.Fatal("second sync task acquired a slot before the first released it")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	p.release("sync")
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("acquire() after release error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("waiting sync task did not get the released slot")
+	}
+}
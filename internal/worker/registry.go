@@ -0,0 +1,210 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+)
+
+// ErrUnknownTaskType 任务类型没有注册处理器
+var ErrUnknownTaskType = errors.New("unknown task type")
+
+// Registry 按任务类型名称注册 Handler，并统一套用中间件
+type Registry struct {
+	mu          sync.RWMutex
+	handlers    map[string]*entry
+	middlewares []Middleware
+}
+
+// entry 单个任务类型的处理器及其设置
+type entry struct {
+	handler     Handler
+	concurrency int                // 0 表示不单独限制
+	retryPolicy *model.RetryPolicy // nil 表示使用 model.DefaultRetryPolicy
+	timeout     time.Duration      // 单次执行的默认超时，0 表示不限制
+	queue       string             // 默认投递的命名队列，空表示 default
+	uniqueTTL   time.Duration      // 唯一任务的去重时间窗口，0 表示不去重
+}
+
+// Option 注册任务类型时的可选设置
+type Option func(*entry)
+
+// WithConcurrency 限制该任务类型在单个 Worker 内的最大并发数
+func WithConcurrency(n int) Option {
+	return func(e *entry) {
+		e.concurrency = n
+	}
+}
+
+// NewRegistry 创建一个空的处理器注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[string]*entry),
+	}
+}
+
+// WithRetryPolicy 设置该任务类型的重试策略，未设置的字段沿用 model.DefaultRetryPolicy
+func WithRetryPolicy(p model.RetryPolicy) Option {
+	return func(e *entry) {
+		e.retryPolicy = &p
+	}
+}
+
+// WithTimeout 设置该任务类型单次执行的默认超时，提交任务时可以用 timeout 覆盖
+func WithTimeout(d time.Duration) Option {
+	return func(e *entry) {
+		e.timeout = d
+	}
+}
+
+// WithQueue 设置该任务类型默认投递的命名队列，配置中的 TASK_ROUTES 优先
+func WithQueue(name string) Option {
+	return func(e *entry) {
+		e.queue = name
+	}
+}
+
+// WithUnique 把该任务类型设为唯一任务：ttl 内类型和 payload 相同（或 unique_key 相同）的任务
+// 尚未结束时不会重复入队，执行时也会加分布式锁，保证同一时间只有一个 Worker 在执行
+func WithUnique(ttl time.Duration) Option {
+	return func(e *entry) {
+		e.uniqueTTL = ttl
+	}
+}
+
+// Register 注册任务类型对应的处理器，重复注册同一类型会 panic
+func (r *Registry) Register(taskType string, h Handler, opts ...Option) {
+	if taskType == "" {
+		panic("worker: empty task type")
+	}
+	if h == nil {
+		panic("worker: nil handler for task type " + taskType)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.handlers[taskType]; exists {
+		panic("worker: duplicate handler for task type " + taskType)
+	}
+	e := &entry{handler: h}
+	for _, opt := range opts {
+		opt(e)
+	}
+	r.handlers[taskType] = e
+}
+
+// RegisterFunc 以函数形式注册处理器
+func (r *Registry) RegisterFunc(taskType string, fn func(ctx context.Context, task *model.Task) error, opts ...Option) {
+	r.Register(taskType, HandlerFunc(fn), opts...)
+}
+
+// Use 添加全局中间件，对所有任务类型生效
+func (r *Registry) Use(mws ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, mws...)
+}
+
+// Has 判断任务类型是否已注册
+func (r *Registry) Has(taskType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.handlers[taskType]
+	return ok
+}
+
+// Types 返回所有已注册的任务类型（按名称排序）
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.handlers))
+	for t := range r.handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Lookup 返回套用了中间件的处理器
+func (r *Registry) Lookup(taskType string) (Handler, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.handlers[taskType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTaskType, taskType)
+	}
+	return Chain(e.handler, r.middlewares...), nil
+}
+
+// Concurrency 返回注册时为任务类型设置的并发上限，0 表示不限制
+func (r *Registry) Concurrency(taskType string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.handlers[taskType]; ok {
+		return e.concurrency
+	}
+	return 0
+}
+
+// RetryPolicy 返回任务类型的重试策略
+func (r *Registry) RetryPolicy(taskType string) model.RetryPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.handlers[taskType]; ok {
+		return model.DefaultRetryPolicy.Merge(e.retryPolicy)
+	}
+	return model.DefaultRetryPolicy
+}
+
+// Timeout 返回任务类型的默认执行超时，0 表示不限制
+func (r *Registry) Timeout(taskType string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.handlers[taskType]; ok {
+		return e.timeout
+	}
+	return 0
+}
+
+// UniqueTTL 返回注册时为任务类型设置的去重时间窗口，0 表示不是唯一任务
+func (r *Registry) UniqueTTL(taskType string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.handlers[taskType]; ok {
+		return e.uniqueTTL
+	}
+	return 0
+}
+
+// Queue 返回注册时为任务类型设置的命名队列，空表示未设置
+func (r *Registry) Queue(taskType string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.handlers[taskType]; ok {
+		return e.queue
+	}
+	return ""
+}
+
+// TaskTypes 返回所有已注册任务类型的设置（按名称排序），Worker 启动时写入数据库供 API 使用
+func (r *Registry) TaskTypes() []model.TaskType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]model.TaskType, 0, len(r.handlers))
+	for name, e := range r.handlers {
+		types = append(types, model.TaskType{
+			Name:        name,
+			Queue:       e.queue,
+			RetryPolicy: e.retryPolicy,
+			Timeout:     model.Duration(e.timeout),
+			UniqueTTL:   model.Duration(e.uniqueTTL),
+		})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+)
+
+func noop(ctx context.Context, task *model.Task) error { return nil }
+
+func TestRegisterDuplicate(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterFunc("email", noop)
+	defer func() {
+		if recover() == nil {
+			t.Error("registering email twice did not panic")
+		}
+	}()
+	r.RegisterFunc("email", noop)
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		taskType string
+		handler  Handler
+	}{
+		{"empty type", "", HandlerFunc(noop)},
+		{"nil handler", "email", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Register() did not panic")
+				}
+			}()
+			NewRegistry().Register(tt.taskType, tt.handler)
+		})
+	}
+}
+
+func TestLookupUnknownType(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterFunc("email", noop)
+	if _, err := r.Lookup("sms"); !errors.Is(err, ErrUnknownTaskType) {
+		t.Errorf("Lookup(sms) error = %v, want ErrUnknownTaskType", err)
+	}
+	if r.Has("sms") {
+		t.Error("Has(sms) = true for an unregistered type")
+	}
+	if got := r.Concurrency("sms"); got != 0 {
+		t.Errorf("Concurrency(sms) = %d, want 0", got)
+	}
+	if got := r.RetryPolicy("sms"); got != model.DefaultRetryPolicy {
+		t.Errorf("RetryPolicy(sms) = %+v, want the default policy", got)
+	}
+}
+
+// TestMiddlewareOrder 先添加的中间件位于外层，在 Lookup 之前或之后添加的中间件都会生效
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, task *model.Task) error {
+				calls = append(calls, name+" before")
+				err := next.ProcessTask(ctx, task)
+				calls = append(calls, name+" after")
+				return err
+			})
+		}
+	}
+
+	r := NewRegistry()
+	r.Use(trace("outer"))
+	r.RegisterFunc("email", func(ctx context.Context, task *model.Task) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	r.Use(trace("inner"))
+
+	h, err := r.Lookup("email")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if err := h.ProcessTask(context.Background(), &model.Task{Type: "email"}); err != nil {
+		t.Fatalf("ProcessTask() error = %v", err)
+	}
+	want := []string{"outer before", "inner before", "handler", "inner after", "outer after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRegistryTypes(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterFunc("sms", noop, WithQueue("bulk"), WithConcurrency(2))
+	r.RegisterFunc("email", noop)
+	if got := r.Types(); !slices.Equal(got, []string{"email", "sms"}) {
+		t.Errorf("Types() = %v, want sorted names", got)
+	}
+	types := r.TaskTypes()
+	if len(types) != 2 || types[1].Name != "sms" || types[1].Queue != "bulk" {
+		t.Errorf("TaskTypes() = %+v, want email and sms with queue bulk", types)
+	}
+	if got := r.Concurrency("sms"); got != 2 {
+		t.Errorf("Concurrency(sms) = %d, want 2", got)
+	}
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+)
+
+// ErrNoTaskResult 在 Worker 传给 Handler 的 context 之外调用 SetResult
+var ErrNoTaskResult = errors.New("SetResult called outside of a task handler")
+
+type resultKey struct{}
+
+// taskResult Handler 通过 SetResult 记录的执行结果，任务成功后保存
+type taskResult struct {
+	mu      sync.Mutex
+	value   model.JSON
+	summary string
+}
+
+// withResult 返回可以通过 SetResult 记录结果的 context
+func withResult(ctx context.Context) (context.Context, *taskResult) {
+	r := &taskResult{}
+	return context.WithValue(ctx, resultKey{}, r), r
+}
+
+// get 返回记录的结果和摘要，没有记录摘要时使用默认的完成说明
+func (r *taskResult) get(task *model.Task) (model.JSON, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	summary := r.summary
+	if summary == "" {
+		summary = fmt.Sprintf("Task %s completed successfully", task.ID)
+	}
+	return r.value, summary
+}
+
+// SetResult 记录任务的结构化结果和人可读的摘要，Handler 成功返回后随任务一起保存
+//
+// v 编码为 JSON 保存在 result 中，summary 为空时使用默认的完成说明；多次调用以最后一次为准，
+// Handler 返回错误时记录的结果被丢弃。
+func SetResult(ctx context.Context, v any, summary string) error {
+	r, ok := ctx.Value(resultKey{}).(*taskResult)
+	if !ok {
+		return ErrNoTaskResult
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode task result: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.value = value
+	r.summary = summary
+	return nil
+}
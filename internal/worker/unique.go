@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/google/uuid"
+)
+
+// UniqueLockTTL 唯一任务执行锁的租期，执行期间每三分之一租期续期一次
+const UniqueLockTTL = 30 * time.Second
+
+// ErrUniqueLockLost 执行锁续期失败时作为 Handler context 的取消原因，锁可能已被其他 Worker 获取
+var ErrUniqueLockLost = errors.New("unique task lock lost")
+
+// lockUnique 获取唯一任务的执行锁并每 ttl/3 续期一次，返回释放锁的函数
+//
+// 锁被其他 Worker 持有时返回 RateLimited 错误，任务稍后重新调度且不计入重试次数；
+// 续期时发现锁已丢失则以 ErrUniqueLockLost 取消 Handler 的 context。
+func lockUnique(task *model.Task, ttl time.Duration, cancel context.CancelCauseFunc) (func(), error) {
+	key := cache.UniqueLockPrefix + task.Type + ":" + task.UniqueKey
+	token := uuid.NewString()
+	ok, err := cache.AcquireLock(key, token, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire unique lock: %w", err)
+	}
+	if !ok {
+		return nil, RateLimited(fmt.Errorf("unique task %s is running on another worker", task.UniqueKey), ttl)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			ok, err := cache.RenewLock(key, token, ttl)
+			if err != nil {
+				// Redis 暂时不可用时锁可能仍然有效，下次再续期
+				log.Printf("⚠️ Failed to renew unique lock for task %s: %v\n", task.ID, err)
+				continue
+			}
+			if !ok {
+				log.Printf("❌ Unique lock for task %s lost, interrupting\n", task.ID)
+				cancel(ErrUniqueLockLost)
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		if err := cache.ReleaseLock(key, token); err != nil {
+			log.Printf("⚠️ Failed to release unique lock for task %s: %v\n", task.ID, err)
+		}
+	}, nil
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/google/uuid"
+)
+
+func uniqueTask() *model.Task {
+	return &model.Task{ID: uuid.New(), Type: "report", UniqueKey: uuid.NewString()}
+}
+
+func uniqueLockHeld(t *testing.T, task *model.Task) bool {
+	t.Helper()
+	_, held, err := cache.LockHolder(cache.UniqueLockPrefix + task.Type + ":" + task.UniqueKey)
+	if err != nil {
+		t.Fatalf("LockHolder() error = %v", err)
+	}
+	return held
+}
+
+func TestLockUniqueRelease(t *testing.T) {
+	task := uniqueTask()
+	_, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	unlock, err := lockUnique(task, UniqueLockTTL, cancel)
+	if err != nil {
+		t.Fatalf("lockUnique() error = %v", err)
+	}
+	// 同一任务的另一次执行被限流，不计入重试次数
+	_, err = lockUnique(task, UniqueLockTTL, cancel)
+	if kind, _ := classify(err); kind != model.ErrorRateLimited {
+		t.Errorf("second lockUnique() error = %v, want a rate limited error", err)
+	}
+
+	unlock()
+	if uniqueLockHeld(t, task) {
+		t.Fatal("lock still held after unlock")
+	}
+	unlock, err = lockUnique(task, UniqueLockTTL, cancel)
+	if err != nil {
+		t.Fatalf("lockUnique() after unlock error = %v", err)
+	}
+	unlock()
+}
+
+// TestLockUniqueRenewal 执行时间超过租期时锁被续期；锁丢失后以 ErrUniqueLockLost 取消 Handler
+func TestLockUniqueRenewal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for several renewals")
+	}
+	task := uniqueTask()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	// 内置 Redis 每秒推进一次时间，租期需要大于推进的步长
+	const ttl = 1500 * time.Millisecond
+	unlock, err := lockUnique(task, ttl, cancel)
+	if err != nil {
+		t.Fatalf("lockUnique() error = %v", err)
+	}
+	defer unlock()
+
+	time.Sleep(2 * ttl)
+	if !uniqueLockHeld(t, task) || ctx.Err() != nil {
+		t.Fatalf("lock not renewed: held = %v, ctx error = %v", uniqueLockHeld(t, task), context.Cause(ctx))
+	}
+
+	if err := cache.RDB.Del(context.Background(), cache.UniqueLockPrefix+task.Type+":"+task.UniqueKey).Err(); err != nil {
+		t.Fatalf("Del() error = %v", err)
+	}
+	select {
+	case <-ctx.Done():
+		if !errors.Is(context.Cause(ctx), ErrUniqueLockLost) {
+			t.Errorf("cancel cause = %v, want ErrUniqueLockLost", context.Cause(ctx))
+		}
+	case <-time.After(ttl):
+		t.Error("handler context not cancelled after the lock was lost")
+	}
+}
@@ -0,0 +1,460 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/WangZhaoye/go-task-processor/internal/cache"
+	"github.com/WangZhaoye/go-task-processor/internal/config"
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/WangZhaoye/go-task-processor/internal/mq"
+	"github.com/WangZhaoye/go-task-processor/internal/service"
+	"github.com/google/uuid"
+)
+
+const (
+	RequeueDelay  = time.Second     // 基础设施异常时放回队列前的等待时间
+	InterruptWait = 5 * time.Second // Handler 的 context 取消后等待其返回的时间
+)
+
+// errMalformedMessage 消息体无法解析为任务
+var errMalformedMessage = errors.New("malformed task message")
+
+// errDeadLetter 任务已标记为死信，消息应转入死信队列
+var errDeadLetter = errors.New("task dead-lettered")
+
+// errInterrupted 任务因 Worker 停止未能执行完成
+var errInterrupted = errors.New("task interrupted by shutdown")
+
+// ErrTaskCancelled 任务被取消时作为 Handler context 的取消原因，可通过 context.Cause 获取
+var ErrTaskCancelled = errors.New("task cancelled")
+
+// ErrTaskTimedOut 任务执行超时时作为 Handler context 的取消原因
+var ErrTaskTimedOut = errors.New("task timed out")
+
+// Worker 从消息队列消费任务，并交给 Registry 中对应的 Handler 处理
+type Worker struct {
+	id       string // 实例标识，记录在任务租约中
+	registry *Registry
+	pool     *pool
+	prefetch int
+	queues   []config.QueueWeight // 消费的命名队列及权重
+	grace    time.Duration        // 停止时等待进行中任务完成的时间
+	leaseTTL time.Duration        // running 任务的租约时长
+
+	stopping   context.Context // 停止信号，收到后不再开始新任务
+	handlerCtx context.Context // 传给 Handler 的 context，宽限期结束后取消
+	wg         sync.WaitGroup
+
+	mu       sync.Mutex
+	inflight map[uuid.UUID]*inflightTask // 正在执行的任务
+}
+
+// inflightTask 正在执行的任务的取消函数，以及任务进入 running 后的版本
+type inflightTask struct {
+	cancel  context.CancelCauseFunc
+	version int64 // StartTask 成功前为 -1
+}
+
+// New 创建 Worker，registry 中必须包含所有需要处理的任务类型
+//
+// 并发设置和消费的队列来自 config.Cfg；按类型的并发上限优先使用配置，其次使用注册时的 WithConcurrency。
+func New(registry *Registry) *Worker {
+	typeLimits := make(map[string]int)
+	for _, taskType := range registry.Types() {
+		if n := registry.Concurrency(taskType); n > 0 {
+			typeLimits[taskType] = n
+		}
+	}
+	for taskType, n := range config.Cfg.WorkerTypeConcurrency {
+		typeLimits[taskType] = n
+	}
+
+	return &Worker{
+		id:       newWorkerID(),
+		registry: registry,
+		pool:     newPool(config.Cfg.WorkerConcurrency, typeLimits),
+		prefetch: config.Cfg.WorkerPrefetch,
+		queues:   config.Cfg.WorkerQueues,
+		grace:    config.Cfg.ShutdownTimeout,
+		leaseTTL: config.Cfg.WorkerLeaseTTL,
+		inflight: make(map[uuid.UUID]*inflightTask),
+	}
+}
+
+// Run 注册消费者并处理消息，直到 ctx 被取消后完成优雅停止
+//
+// 消息采用手动确认：只有任务状态成功落库（完成、失败或已安排重试）后才 ack。
+// 执行期间 Worker 定期为任务续租；Worker 崩溃后任务的租约过期，由调度器按重试策略回收，
+// broker 重新投递的原消息因版本不一致被跳过。
+//
+// ctx 取消后：停止消费，尚未开始的消息 nack 回队列，进行中的任务在宽限期内继续执行；
+// 宽限期结束后取消 Handler 的 context，被中断的任务恢复为 pending 并放回队列。
+func (w *Worker) Run(ctx context.Context) error {
+	// 先公布本 Worker 处理的任务类型及其设置，API 据此接受这些类型的任务
+	if err := service.RegisterTaskTypes(w.id, w.registry.TaskTypes()); err != nil {
+		return err
+	}
+
+	subscriptions := make([]<-chan mq.Delivery, 0, len(w.queues))
+	for _, q := range w.queues {
+		msgs, err := mq.Default.Consume(ctx, q.Name, w.prefetchShare(q.Weight))
+		if err != nil {
+			return fmt.Errorf("failed to consume queue %s: %w", q.Name, err)
+		}
+		subscriptions = append(subscriptions, msgs)
+	}
+	log.Printf("🚀 Worker %s started with task types %v on queues %v (concurrency=%d, prefetch=%d). Waiting for tasks...\n",
+		w.id, w.registry.Types(), w.queues, cap(w.pool.slots), w.prefetch)
+
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
+	defer cancelHandlers()
+	w.stopping = ctx
+	w.handlerCtx = handlerCtx
+
+	// 订阅取消信号、为执行中的任务续期，直到 Worker 完全停止
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+	go w.listenCancel(listenCtx)
+	go w.heartbeat(listenCtx)
+
+	// 每个队列一个消费循环，ctx 取消后 broker 停止投递并把未分发的消息放回队列
+	var consuming sync.WaitGroup
+	for _, msgs := range subscriptions {
+		consuming.Add(1)
+		go func() {
+			defer consuming.Done()
+			for d := range msgs {
+				w.wg.Add(1)
+				go w.handleDelivery(d)
+			}
+		}()
+	}
+	consuming.Wait()
+	log.Println("🛑 Worker stopped consuming, waiting for in-flight tasks...")
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("✅ All in-flight tasks finished")
+		return nil
+	case <-time.After(w.grace):
+	}
+
+	// 宽限期结束，通知 Handler 中断执行
+	log.Printf("⏰ Shutdown grace period %v exceeded, interrupting in-flight tasks\n", w.grace)
+	cancelHandlers()
+	select {
+	case <-done:
+	case <-time.After(InterruptWait):
+		// Handler 没有响应取消信号，直接把任务恢复为 pending，消息在连接关闭后回到队列
+		w.releaseInflight()
+	}
+	return nil
+}
+
+// prefetchShare 按队列权重分配预取数量，每个队列至少为 1
+//
+// 预取数量决定了同时从该队列取出的未确认消息上限，权重高的队列会占用更多的执行槽位。
+func (w *Worker) prefetchShare(weight int) int {
+	total := 0
+	for _, q := range w.queues {
+		total += q.Weight
+	}
+	return max(1, w.prefetch*weight/total)
+}
+
+// handleDelivery 处理一条消息，并根据处理结果 ack 或 nack
+func (w *Worker) handleDelivery(d mq.Delivery) {
+	defer w.wg.Done()
+
+	var task model.Task
+	err := json.Unmarshal(d.Body, &task)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errMalformedMessage, err)
+	} else if err = w.pool.acquire(w.stopping, task.Type); err == nil {
+		err = w.handleTask(&task)
+		w.pool.release(task.Type)
+	} else {
+		err = errInterrupted
+	}
+
+	switch {
+	case err == nil:
+		if ackErr := d.Ack(); ackErr != nil {
+			log.Printf("❌ Failed to ack message: %v\n", ackErr)
+		}
+	case errors.Is(err, errDeadLetter), errors.Is(err, errMalformedMessage):
+		// 不重新入队，broker 会把消息转入死信队列
+		if errors.Is(err, errMalformedMessage) {
+			log.Printf("❌ Dead-lettering message: %v\n", err)
+		}
+		if nackErr := d.Nack(false); nackErr != nil {
+			log.Printf("❌ Failed to nack message: %v\n", nackErr)
+		}
+	case errors.Is(err, service.ErrTaskState):
+		// 任务已被取消、已完成、已重新入队或已被其他 Worker 修改，属于重复或过期的投递
+		log.Printf("⏭️ Skipping stale delivery: %v\n", err)
+		if ackErr := d.Ack(); ackErr != nil {
+			log.Printf("❌ Failed to ack message: %v\n", ackErr)
+		}
+	case errors.Is(err, errInterrupted):
+		// Worker 正在停止，任务放回队列由其他 Worker 处理
+		if nackErr := d.Nack(true); nackErr != nil {
+			log.Printf("❌ Failed to nack message: %v\n", nackErr)
+		}
+	default:
+		// 数据库、Redis 或 MQ 暂时不可用，稍等后放回队列
+		log.Printf("⚠️ Requeueing message after infrastructure error: %v\n", err)
+		time.Sleep(RequeueDelay)
+		if nackErr := d.Nack(true); nackErr != nil {
+			log.Printf("❌ Failed to nack message: %v\n", nackErr)
+		}
+	}
+}
+
+// handleTask 处理任务，返回 nil 表示任务状态已落库、消息可以确认；
+// 返回错误表示消息需要重新投递
+func (w *Worker) handleTask(task *model.Task) error {
+	// 未注册的任务类型不再交给默认处理器，直接进入死信
+	handler, err := w.registry.Lookup(task.Type)
+	if err != nil {
+		log.Printf("💀 Task %s rejected: %v\n", task.ID, err)
+		if err := service.MarkTaskDead(task.ID, task.Version, "Task rejected: no handler registered", err.Error(), model.ErrorPermanent); err != nil {
+			return fmt.Errorf("failed to mark task as dead: %w", err)
+		}
+		return errDeadLetter
+	}
+
+	// 超过 expires_at 仍未开始执行的任务直接丢弃，不再延迟执行
+	if task.ExpiresAt != nil && time.Now().After(*task.ExpiresAt) {
+		if err := service.ExpireTask(task.ID, task.Version); err != nil {
+			return fmt.Errorf("failed to expire task: %w", err)
+		}
+		log.Printf("⌛ Task %s expired at %s, discarded\n", task.ID, task.ExpiresAt.Format(time.RFC3339))
+		return nil
+	}
+
+	// 先登记取消函数再切换为 running，保证任务进入 running 后发出的取消信号一定能送达
+	ctx, cancel := context.WithCancelCause(w.handlerCtx)
+	defer cancel(nil)
+	if !w.trackInflight(task.ID, cancel) {
+		// 连接重建后 broker 可能把本 Worker 正在执行的消息再投递一次
+		return fmt.Errorf("%w: task %s is already running on this worker", service.ErrTaskState, task.ID)
+	}
+	defer w.untrackInflight(task.ID)
+
+	// 更新状态为 running；消息版本与任务不一致时是重复或过期的投递，直接跳过
+	version, err := service.StartTask(task.ID, task.Version, w.id, time.Now().Add(w.leaseTTL))
+	if err != nil {
+		return fmt.Errorf("failed to update task to running: %w", err)
+	}
+	task.Version = version
+	w.setInflightVersion(task.ID, version)
+
+	// 唯一任务加执行锁，同一时间只有一个 Worker 在执行
+	if task.UniqueKey != "" {
+		unlock, err := lockUnique(task, UniqueLockTTL, cancel)
+		var taskErr *TaskError
+		if errors.As(err, &taskErr) {
+			log.Printf("🔒 Task %s: %v\n", task.ID, err)
+			return w.handleTaskFailure(task, err)
+		}
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	// 提交时指定的超时优先，其次是任务类型的默认超时
+	timeout := time.Duration(task.Timeout)
+	if timeout <= 0 {
+		timeout = w.registry.Timeout(task.Type)
+	}
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, timeout, ErrTaskTimedOut)
+		defer cancelTimeout()
+	}
+
+	// 执行任务处理，Handler 通过 SetResult 记录结果
+	ctx, result := withResult(ctx)
+	err = runHandler(ctx, handler, task)
+	if err != nil {
+		switch {
+		case errors.Is(context.Cause(ctx), ErrTaskTimedOut):
+			// 超时按普通失败处理，计入重试次数
+			err = &TaskError{Kind: model.ErrorTimedOut, Err: fmt.Errorf("exceeded timeout %v: %w", timeout, err)}
+		case errors.Is(context.Cause(ctx), ErrLeaseLost):
+			// 任务已被回收并重新安排，结果作废
+			return fmt.Errorf("%w: task %s lease lost during execution", service.ErrTaskState, task.ID)
+		case errors.Is(context.Cause(ctx), ErrUniqueLockLost):
+			// 其他 Worker 可能已经拿到锁，稍后重新调度，不计入重试次数
+			err = RateLimited(fmt.Errorf("%w: %v", ErrUniqueLockLost, err), UniqueLockTTL)
+		case errors.Is(context.Cause(ctx), ErrTaskCancelled):
+			// 取消请求已把任务标记为 cancelled，这里只需确认消息
+			log.Printf("🚫 Task %s cancelled during execution\n", task.ID)
+			return nil
+		case w.handlerCtx.Err() != nil:
+			// 因 Worker 停止被中断，恢复为 pending 并重新入队，当前消息可以确认
+			log.Printf("⏸️ Task %s interrupted by shutdown: %v\n", task.ID, err)
+			if err := service.ReleaseTask(task.ID, task.Version); err != nil {
+				log.Printf("❌ Failed to reset interrupted task %s: %v\n", task.ID, err)
+				return errInterrupted
+			}
+			return nil
+		}
+		log.Printf("❌ Task %s failed: %v\n", task.ID, err)
+		return w.handleTaskFailure(task, err)
+	}
+
+	// 任务成功完成
+	value, summary := result.get(task)
+	if err := service.CompleteTask(task.ID, task.Version, value, summary); err != nil {
+		return fmt.Errorf("failed to finish task: %w", err)
+	}
+	log.Printf("✅ Task %s done. \n", task.ID)
+	return nil
+}
+
+// runHandler 执行 Handler；ctx 结束后 Handler 迟迟不返回时放弃等待，
+// 避免不响应取消的 Handler 突破超时限制
+//
+// Handler 在独立的 goroutine 中执行，其中的 panic 无法被调用方捕获，会使整个进程退出，
+// 因此在 goroutine 内恢复并转换为永久错误：同样的输入重试大概率还会 panic。
+func runHandler(ctx context.Context, handler Handler, task *model.Task) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("❌ Task %s handler panicked: %v\n%s", task.ID, r, debug.Stack())
+				done <- Permanent(fmt.Errorf("handler panic: %v", r))
+			}
+		}()
+		done <- handler.ProcessTask(ctx, task)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(InterruptWait):
+		log.Printf("⚠️ Task %s handler ignored cancellation, abandoning it\n", task.ID)
+		return context.Cause(ctx)
+	}
+}
+
+// handleTaskFailure 处理任务失败，按错误分类和重试策略决定重试还是进入死信
+//
+// 重试不在内存中等待：任务状态改为 retrying 并记录 next_run_at，
+// 由调度器在到期后重新发布，Worker 重启也不会丢失重试。
+func (w *Worker) handleTaskFailure(task *model.Task, taskErr error) error {
+	policy := w.registry.RetryPolicy(task.Type).Merge(task.RetryPolicy)
+	attempts := task.RetryCount + 1
+	kind, delay := classify(taskErr)
+
+	switch {
+	case kind == model.ErrorRateLimited:
+		// 限流不是任务本身的问题，不消耗重试次数
+		if delay <= 0 {
+			delay = policy.Backoff(attempts)
+		}
+		log.Printf("🚦 Task %s rate limited, retrying after %v\n", task.ID, delay)
+	case kind != model.ErrorPermanent && attempts < policy.MaxAttempts:
+		// 还可以重试，优先使用处理器建议的等待时间
+		task.RetryCount++
+		if delay <= 0 {
+			delay = policy.Backoff(task.RetryCount)
+		}
+		log.Printf("🔄 Retrying task %s (attempt %d/%d) after %v\n",
+			task.ID, attempts+1, policy.MaxAttempts, delay)
+	default:
+		// 永久错误或达到最大执行次数，标记为死信并把消息转入死信队列
+		log.Printf("💀 Task %s dead-lettered after %d attempts (%s)\n", task.ID, attempts, kind)
+		errorMsg := fmt.Sprintf("Task failed after %d attempts. Last error: %v", attempts, taskErr)
+		if err := service.MarkTaskDead(task.ID, task.Version, errorMsg, taskErr.Error(), kind); err != nil {
+			return fmt.Errorf("failed to mark task as dead: %w", err)
+		}
+		return errDeadLetter
+	}
+
+	if err := service.ScheduleRetry(task.ID, task.Version, task.RetryCount, taskErr.Error(), kind, time.Now().Add(delay)); err != nil {
+		return fmt.Errorf("failed to schedule retry: %w", err)
+	}
+	return nil
+}
+
+// trackInflight 记录正在执行的任务，用于取消任务和停止超时时恢复任务状态；任务已在执行时返回 false
+func (w *Worker) trackInflight(id uuid.UUID, cancel context.CancelCauseFunc) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.inflight[id]; ok {
+		return false
+	}
+	w.inflight[id] = &inflightTask{cancel: cancel, version: -1}
+	return true
+}
+
+// setInflightVersion 记录任务进入 running 后的版本，停止超时时用它恢复任务状态
+func (w *Worker) setInflightVersion(id uuid.UUID, version int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t, ok := w.inflight[id]; ok {
+		t.version = version
+	}
+}
+
+func (w *Worker) untrackInflight(id uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.inflight, id)
+}
+
+// listenCancel 接收其他实例广播的取消信号，取消本 Worker 上正在执行的对应任务
+func (w *Worker) listenCancel(ctx context.Context) {
+	for raw := range cache.SubscribeTaskCancel(ctx) {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			log.Printf("⚠️ Ignoring invalid cancel signal %q\n", raw)
+			continue
+		}
+		w.mu.Lock()
+		t, ok := w.inflight[id]
+		w.mu.Unlock()
+		if ok {
+			log.Printf("🚫 Cancelling running task %s\n", id)
+			t.cancel(ErrTaskCancelled)
+		}
+	}
+}
+
+// releaseInflight 把仍在执行的任务恢复为 pending 并重新入队
+func (w *Worker) releaseInflight() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, t := range w.inflight {
+		if t.version < 0 {
+			continue
+		}
+		log.Printf("⏸️ Task %s did not stop in time, resetting to pending\n", id)
+		if err := service.ReleaseTask(id, t.version); err != nil {
+			log.Printf("❌ Failed to reset interrupted task %s: %v\n", id, err)
+		}
+	}
+}
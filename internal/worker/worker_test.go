@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/WangZhaoye/go-task-processor/internal/model"
+	"github.com/google/uuid"
+)
+
+// TestRunHandlerPanic Handler 的 panic 在它自己的 goroutine 中被恢复，转换为不再重试的永久错误
+func TestRunHandlerPanic(t *testing.T) {
+	task := &model.Task{ID: uuid.New(), Type: "email"}
+	handler := HandlerFunc(func(ctx context.Context, task *model.Task) error {
+		var m map[string]int
+		m["boom"]++ // 向 nil map 写入会 panic
+		return nil
+	})
+
+	err := runHandler(context.Background(), handler, task)
+	if err == nil {
+		t.Fatal("runHandler() returned nil for a panicking handler")
+	}
+	if kind, _ := classify(err); kind != model.ErrorPermanent {
+		t.Errorf("panic classified as %s, want %s", kind, model.ErrorPermanent)
+	}
+}
+
+func TestRunHandlerResult(t *testing.T) {
+	want := errors.New("smtp unavailable")
+	handler := HandlerFunc(func(ctx context.Context, task *model.Task) error { return want })
+	if err := runHandler(context.Background(), handler, &model.Task{ID: uuid.New()}); err != want {
+		t.Errorf("runHandler() error = %v, want %v", err, want)
+	}
+}
@@ -46,7 +46,7 @@ create_task() {
     local task_num=$1
     local start_time=$(python3 -c "import time; print(int(time.time() * 1000))")
     
-    local task_type="concurrent_test"
+    local task_type="data_sync"
     local payload="Concurrent task #$task_num - $(date)"
     
     local response=$(curl -s -X POST "$API_URL/tasks" \
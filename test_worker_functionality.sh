@@ -46,7 +46,7 @@ TASK_RESPONSES=()
 TASK_IDS=()
 
 # 创建不同类型的任务
-TASK_TYPES=("email" "data_sync" "report" "notification" "cleanup")
+TASK_TYPES=("email" "data_sync")
 for i in "${!TASK_TYPES[@]}"; do
     TYPE=${TASK_TYPES[$i]}
     TASK_RESPONSE=$(curl -s -X POST "$API_URL/tasks" \